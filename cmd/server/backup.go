@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/imbalaancing/go_final_project/internal/backup"
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+const (
+	backupUsage  = "использование: backup [файл]"
+	restoreUsage = "использование: restore файл"
+)
+
+// newBackups настраивает копии базы по TODO_BACKUP_DIR и TODO_BACKUP_KEEP.
+func newBackups(database *sql.DB) (*backup.Backups, error) {
+	dir := os.Getenv("TODO_BACKUP_DIR")
+	if dir == "" {
+		dir = "backups"
+	}
+	keep := backup.DefaultKeep
+	if value := os.Getenv("TODO_BACKUP_KEEP"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("неверное значение TODO_BACKUP_KEEP: %s", value)
+		}
+		keep = n
+	}
+	return backup.New(database, dir, keep), nil
+}
+
+// backupCommand сохраняет копию базы, которую выбрал бы сервер:
+//
+//	backup        в каталог TODO_BACKUP_DIR, удаляя копии сверх TODO_BACKUP_KEEP;
+//	backup файл   в указанный файл, которого ещё нет.
+func backupCommand(args []string) error {
+	if len(args) > 1 {
+		return errors.New(backupUsage)
+	}
+	database, err := openSQLite()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if len(args) == 1 {
+		if _, err := os.Stat(args[0]); err == nil {
+			return fmt.Errorf("файл %s уже существует", args[0])
+		}
+		if err := db.Backup(context.Background(), database, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Копия базы сохранена в %s\n", args[0])
+		return nil
+	}
+
+	backups, err := newBackups(database)
+	if err != nil {
+		return err
+	}
+	f, err := backups.Create(context.Background())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Копия базы сохранена в %s (%d байт)\n", filepath.Join(backups.Dir, f.Name), f.Size)
+	return nil
+}
+
+// restoreCommand заменяет базу копией из файла. Копия сначала проверяется на
+// целостность и версию схемы, а текущая база сохраняется в каталог копий.
+func restoreCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(restoreUsage)
+	}
+	version, err := db.CheckBackup(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Копия %s цела, версия схемы %d.\n", args[0], version)
+
+	_, statErr := os.Stat(dbFile())
+	database, err := openSQLite()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if statErr != nil {
+		// Базы ещё нет, сохранять перед восстановлением нечего.
+		if err := db.Restore(context.Background(), database, args[0]); err != nil {
+			return err
+		}
+	} else {
+		backups, err := newBackups(database)
+		if err != nil {
+			return err
+		}
+		f, err := backups.Restore(context.Background(), args[0])
+		if f.Name != "" {
+			fmt.Printf("База до восстановления сохранена в %s\n", filepath.Join(backups.Dir, f.Name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Printf("База %s восстановлена из %s\n", dbFile(), args[0])
+	return nil
+}
+
+// openSQLite открывает базу SQLite сервера, не меняя её схему.
+func openSQLite() (*sql.DB, error) {
+	dbFileName := dbFile()
+	if os.Getenv("TODO_DATABASE_URL") != "" || dbFileName == db.MemoryDSN || strings.HasSuffix(dbFileName, db.JournalExt) {
+		return nil, errors.New("резервное копирование доступно только для базы SQLite")
+	}
+	return db.OpenDB(dbFileName)
+}
@@ -4,24 +4,151 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/imbalaancing/go_final_project/internal/api"
+	"github.com/imbalaancing/go_final_project/internal/backup"
 	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/pomodoro"
+	"github.com/imbalaancing/go_final_project/internal/rollover"
+	"github.com/imbalaancing/go_final_project/internal/task"
+	"github.com/imbalaancing/go_final_project/internal/undo"
 )
 
 func main() {
-	dbFileName := os.Getenv("TODO_DBFILE")
-	if dbFileName == "" {
-		dbFileName = "scheduler.db"
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrateCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Ошибка миграции: %v", err)
+			}
+			return
+		case "backup":
+			if err := backupCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Ошибка резервного копирования: %v", err)
+			}
+			return
+		case "restore":
+			if err := restoreCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Ошибка восстановления: %v", err)
+			}
+			return
+		}
+	}
+
+	// Часовой пояс определяет, какой день считается сегодняшним: для переноса
+	// просроченных задач, признака overdue и разделов списка задач.
+	if tz := os.Getenv("TODO_TZ"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			log.Fatalf("Неверное значение TODO_TZ: %s", tz)
+		}
+		time.Local = loc
+	}
+
+	dbFileName := dbFile()
+
+	var storage db.TaskStore
+	var backups *backup.Backups
+	if dsn := os.Getenv("TODO_DATABASE_URL"); dsn != "" {
+		database, err := db.InitPostgres(dsn)
+		if err != nil {
+			log.Fatalf("Ошибка подключения к PostgreSQL: %v", err)
+		}
+		defer database.Close()
+		storage = db.NewPostgresStorage(database)
+	} else if dbFileName == db.MemoryDSN {
+		storage = db.NewMemoryStorage()
+		log.Println("Данные хранятся в памяти и будут потеряны при остановке сервера.")
+	} else if strings.HasSuffix(dbFileName, db.JournalExt) {
+		journal, err := db.OpenJournal(dbFileName)
+		if err != nil {
+			log.Fatalf("Ошибка открытия журнала: %v", err)
+		}
+		defer journal.Close()
+		storage = journal
+		log.Printf("Данные хранятся в журнале %s.", dbFileName)
+	} else {
+		database, err := db.InitDB(dbFileName)
+		if err != nil {
+			log.Fatalf("Ошибка инициализации базы данных: %v", err)
+		}
+		defer database.Close()
+		storage = db.NewTaskStorage(database)
+
+		backups, err = newBackups(database)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if interval := os.Getenv("TODO_BACKUP_INTERVAL"); interval != "" {
+			d, err := time.ParseDuration(interval)
+			if err != nil || d <= 0 {
+				log.Fatalf("Неверное значение TODO_BACKUP_INTERVAL: %s", interval)
+			}
+			backups.Start(d)
+		}
+	}
+
+	if seed := os.Getenv("TODO_SEED"); seed != "" {
+		n, err := db.Seed(storage, seed)
+		if err != nil {
+			log.Fatalf("Ошибка загрузки начальных данных: %v", err)
+		}
+		log.Printf("Загружено задач из %s: %d", seed, n)
+	}
+
+	task.StrictDates = os.Getenv("TODO_STRICT_DATES") == "true"
+	api.AdminToken = os.Getenv("TODO_ADMIN_TOKEN")
+
+	if ttl := os.Getenv("TODO_DELETED_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d < 0 {
+			log.Fatalf("Неверное значение TODO_DELETED_TTL: %s", ttl)
+		}
+		rollover.DeletedTTL = d
+	}
+
+	if ttl := os.Getenv("TODO_UNDO_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			log.Fatalf("Неверное значение TODO_UNDO_TTL: %s", ttl)
+		}
+		api.UndoStack = undo.NewStack(d)
+	}
+
+	if dir := os.Getenv("TODO_ATTACHMENTS_DIR"); dir != "" {
+		if err := storage.SetAttachmentsDir(dir); err != nil {
+			log.Fatalf("Ошибка создания каталога вложений: %v", err)
+		}
+	}
+	if size := os.Getenv("TODO_ATTACHMENTS_MAX_SIZE"); size != "" {
+		maxSize, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || maxSize < 1 {
+			log.Fatalf("Неверное значение TODO_ATTACHMENTS_MAX_SIZE: %s", size)
+		}
+		api.MaxAttachmentSize = maxSize
+	}
+	if timeout := os.Getenv("TODO_QUERY_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d < 0 {
+			log.Fatalf("Неверное значение TODO_QUERY_TIMEOUT: %s", timeout)
+		}
+		db.QueryTimeout = d
 	}
+	rollover.Start(storage)
 
-	database, err := db.InitDB(dbFileName)
+	focus, err := pomodoro.NewManager(storage)
 	if err != nil {
-		log.Fatalf("Ошибка инициализации базы данных: %v", err)
+		log.Fatalf("Ошибка восстановления сессии помодоро: %v", err)
 	}
-	defer database.Close()
 
-	storage := db.NewTaskStorage(database)
+	// Запросы обработчиков к базе отменяются, когда клиент закрывает соединение.
+	store := func(r *http.Request) db.TaskStore {
+		return storage.WithContext(r.Context())
+	}
 
 	fs := http.FileServer(http.Dir("./web"))
 	http.Handle("/", fs)
@@ -31,13 +158,13 @@ func main() {
 	http.HandleFunc("/api/task", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
-			api.AddTaskHandler(w, r, storage)
+			api.AddTaskHandler(w, r, store(r))
 		case http.MethodGet:
-			api.GetTaskHandler(w, r, storage)
+			api.GetTaskHandler(w, r, store(r))
 		case http.MethodPut:
-			api.UpdateTaskHandler(w, r, storage)
+			api.UpdateTaskHandler(w, r, store(r))
 		case http.MethodDelete:
-			api.DeleteTaskHandler(w, r, storage)
+			api.DeleteTaskHandler(w, r, store(r))
 		default:
 			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
 		}
@@ -45,7 +172,132 @@ func main() {
 
 	http.HandleFunc("/api/task/done", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			api.MarkTaskDoneHandler(w, r, storage)
+			api.MarkTaskDoneHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/attachments", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			api.UploadAttachmentHandler(w, r, store(r))
+		case http.MethodGet:
+			api.GetAttachmentsHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/attachment", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.DownloadAttachmentHandler(w, r, store(r))
+		case http.MethodDelete:
+			api.DeleteAttachmentHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/notes", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			api.AddNoteHandler(w, r, store(r))
+		case http.MethodGet:
+			api.GetNotesHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/note", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.GetNoteHandler(w, r, store(r))
+		case http.MethodPut:
+			api.UpdateNoteHandler(w, r, store(r))
+		case http.MethodDelete:
+			api.DeleteNoteHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/fields", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.GetFieldsHandler(w, r, store(r))
+		case http.MethodPost:
+			api.AddFieldHandler(w, r, store(r))
+		case http.MethodPut:
+			api.UpdateFieldHandler(w, r, store(r))
+		case http.MethodDelete:
+			api.DeleteFieldHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/revisions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.GetRevisionsHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/revert", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.RevertTaskHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/undo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.UndoHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/templates", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.GetTemplatesHandler(w, r, store(r))
+		case http.MethodPost:
+			api.AddTemplateHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/template", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.GetTemplateHandler(w, r, store(r))
+		case http.MethodPut:
+			api.UpdateTemplateHandler(w, r, store(r))
+		case http.MethodDelete:
+			api.DeleteTemplateHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/template/instantiate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.InstantiateTemplateHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/quick", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.QuickAddTaskHandler(w, r, store(r))
 		} else {
 			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
 		}
@@ -53,12 +305,135 @@ func main() {
 
 	http.HandleFunc("/api/tasks", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
-			api.GetTasksHandler(w, r, storage)
+			api.GetTasksHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/views", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.GetViewsHandler(w, r, store(r))
+		case http.MethodPost:
+			api.AddViewHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/views/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			api.GetViewHandler(w, r, store(r))
+		case http.MethodPut:
+			api.UpdateViewHandler(w, r, store(r))
+		case http.MethodDelete:
+			api.DeleteViewHandler(w, r, store(r))
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/views/{id}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.GetViewTasksHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/tasks/overdue", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.GetOverdueTasksHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/tasks/reschedule", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.RescheduleTasksHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/task/timer/start", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.StartTimerHandler(w, r, store(r))
 		} else {
 			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
 		}
 	})
 
+	http.HandleFunc("/api/task/timer/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.StopTimerHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/reports/time", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.TimeReportHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/pomodoro", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.GetPomodoroHandler(w, r, focus)
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/pomodoro/start", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.StartPomodoroHandler(w, r, focus)
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/pomodoro/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			api.StopPomodoroHandler(w, r, focus)
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/pomodoro/events", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.PomodoroEventsHandler(w, r, focus)
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/pomodoro/summary", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			api.FocusSummaryHandler(w, r, store(r))
+		} else {
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/api/admin/backup", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			api.CreateBackupHandler(w, r, backups)
+		case http.MethodGet:
+			api.GetBackupsHandler(w, r, backups)
+		default:
+			http.Error(w, "Неподдерживаемый метод", http.StatusMethodNotAllowed)
+		}
+	})
+
 	port := os.Getenv("TODO_PORT")
 	if port == "" {
 		port = "7540"
@@ -70,3 +445,11 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// dbFile возвращает путь к базе данных из TODO_DBFILE.
+func dbFile() string {
+	if name := os.Getenv("TODO_DBFILE"); name != "" {
+		return name
+	}
+	return "scheduler.db"
+}
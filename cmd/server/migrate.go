@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+const migrateUsage = "использование: migrate up|down|status"
+
+// migrateCommand выполняет команду migrate для базы, которую выбрал бы сервер:
+//
+//	migrate up      применить все недостающие миграции;
+//	migrate down    откатить последнюю применённую миграцию;
+//	migrate status  показать применённые и недостающие миграции.
+func migrateCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	database, migrator, err := openMigrator()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	switch args[0] {
+	case "up":
+		n, err := migrator.Up()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Применено миграций: %d\n", n)
+	case "down":
+		version, err := migrator.Down()
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			fmt.Println("Нет применённых миграций.")
+		}
+	case "status":
+		list, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, m := range list {
+			name, state := m.Name, "применена "+m.AppliedAt
+			if name == "" {
+				name = "(неизвестна)"
+			}
+			if m.AppliedAt == "" {
+				state = "не применена"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, name, state)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
+
+// openMigrator открывает базу по тем же настройкам, что и сервер, не меняя её схему.
+func openMigrator() (*sql.DB, *db.Migrator, error) {
+	var database *sql.DB
+	var err error
+	var newMigrator func(*sql.DB) (*db.Migrator, error)
+	if dsn := os.Getenv("TODO_DATABASE_URL"); dsn != "" {
+		database, err = db.OpenPostgres(dsn)
+		newMigrator = db.NewPostgresMigrator
+	} else {
+		dbFileName := dbFile()
+		if dbFileName == db.MemoryDSN || strings.HasSuffix(dbFileName, db.JournalExt) {
+			return nil, nil, fmt.Errorf("у хранилища %s нет схемы для миграций", dbFileName)
+		}
+		database, err = db.OpenDB(dbFileName)
+		newMigrator = db.NewSQLiteMigrator
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	migrator, err := newMigrator(database)
+	if err != nil {
+		database.Close()
+		return nil, nil, err
+	}
+	return database, migrator, nil
+}
@@ -4,8 +4,10 @@ go 1.22.3
 
 require (
 	github.com/jmoiron/sqlx v1.4.0
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/stretchr/testify v1.9.0
+	github.com/yuin/goldmark v1.8.6
 )
 
 require (
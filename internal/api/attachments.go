@@ -0,0 +1,135 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+// MaxAttachmentSize — наибольший размер загружаемого файла в байтах.
+var MaxAttachmentSize int64 = 10 << 20
+
+func UploadAttachmentHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, `{"error":"Файл слишком большой"}`, http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, `{"error":"Не передан файл"}`, http.StatusBadRequest)
+		}
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, MaxAttachmentSize+1))
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка чтения файла"}`, http.StatusBadRequest)
+		return
+	}
+	if int64(len(content)) > MaxAttachmentSize {
+		http.Error(w, `{"error":"Файл слишком большой"}`, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	name := filepath.Base(strings.ReplaceAll(header.Filename, `\`, `/`))
+	if name == "." || name == "/" {
+		name = "file"
+	}
+	// Тип определяем по содержимому, а не по заголовку клиента.
+	attachment := db.Attachment{
+		TaskID:      id,
+		Name:        name,
+		ContentType: http.DetectContentType(content),
+	}
+
+	attachmentID, err := storage.InsertAttachment(attachment, content)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка сохранения вложения")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{"id": strconv.FormatInt(attachmentID, 10)})
+}
+
+func GetAttachmentsHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	attachments, err := storage.GetAttachments(id)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения вложений")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]db.Attachment{"attachments": attachments})
+}
+
+func DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	attachment, content, err := storage.GetAttachment(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Вложение не найдено"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка получения вложения")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(content)
+}
+
+func DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	rowsAffected, err := storage.DeleteAttachment(id)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка удаления вложения")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Вложение не найдено"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/imbalaancing/go_final_project/internal/backup"
+)
+
+// AdminToken — токен административных запросов из TODO_ADMIN_TOKEN. Пока он
+// не задан, административные запросы отклоняются.
+var AdminToken string
+
+// checkAdmin проверяет заголовок Authorization: Bearer <токен>.
+func checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if AdminToken == "" {
+		http.Error(w, `{"error":"Административные запросы отключены: не задана TODO_ADMIN_TOKEN"}`, http.StatusForbidden)
+		return false
+	}
+	token := r.Header.Get("Authorization")
+	if subtle.ConstantTimeCompare([]byte(token), []byte("Bearer "+AdminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, `{"error":"Неверный токен администратора"}`, http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// CreateBackupHandler создаёт копию базы. backups равен nil, если хранилище
+// не SQLite.
+func CreateBackupHandler(w http.ResponseWriter, r *http.Request, backups *backup.Backups) {
+	if !checkAdmin(w, r) {
+		return
+	}
+	if backups == nil {
+		http.Error(w, `{"error":"Резервное копирование доступно только для базы SQLite"}`, http.StatusNotImplemented)
+		return
+	}
+
+	f, err := backups.Create(r.Context())
+	if err != nil {
+		writeStorageError(w, err, "Ошибка резервного копирования")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(f)
+}
+
+func GetBackupsHandler(w http.ResponseWriter, r *http.Request, backups *backup.Backups) {
+	if !checkAdmin(w, r) {
+		return
+	}
+	if backups == nil {
+		http.Error(w, `{"error":"Резервное копирование доступно только для базы SQLite"}`, http.StatusNotImplemented)
+		return
+	}
+
+	files, err := backups.List()
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка получения списка копий"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]any{"backups": files})
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// etag возвращает ETag задачи: её версию в кавычках.
+func etag(t task.Task) string {
+	return `"` + strconv.FormatInt(t.Version, 10) + `"`
+}
+
+// matchETag проверяет, есть ли etag в списке заголовка If-Match. Слабые теги
+// W/"..." с ним не совпадают, * совпадает с любым.
+func matchETag(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch проверяет заголовок If-Match запроса на изменение задачи id и
+// возвращает её текущую версию, которую надо передать в UpdateTask,
+// MarkTaskDone или DeleteTask. Без заголовка возвращается 0: задача меняется
+// без проверки. Если задача не найдена или изменилась, клиент получает 412 и
+// checkIfMatch возвращает false.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, storage db.TaskStore, id string) (int64, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, true
+	}
+
+	t, err := storage.GetTask(id)
+	if err != nil && err != sql.ErrNoRows {
+		writeStorageError(w, err, "Ошибка получения задачи")
+		return 0, false
+	}
+	if err == sql.ErrNoRows || !matchETag(header, etag(t)) {
+		http.Error(w, `{"error":"Задача изменена или удалена, обновите её и повторите запрос"}`, http.StatusPreconditionFailed)
+		return 0, false
+	}
+	return t.Version, true
+}
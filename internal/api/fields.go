@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// parseFieldParams разбирает параметры field.<имя>=<значение> и sort=[-]field.<имя>.
+func parseFieldParams(params url.Values, storage db.TaskStore, filter *db.TaskFilter) error {
+	sort := params.Get("sort")
+	hasFields := strings.Contains(sort, "field.")
+	for key := range params {
+		hasFields = hasFields || strings.HasPrefix(key, "field.")
+	}
+	if !hasFields {
+		return nil
+	}
+
+	defs, err := storage.GetFieldDefs()
+	if err != nil {
+		return fmt.Errorf("ошибка получения пользовательских полей")
+	}
+	byName := make(map[string]task.FieldDef, len(defs))
+	for _, d := range defs {
+		byName[d.Name] = d
+	}
+
+	for key, values := range params {
+		name, ok := strings.CutPrefix(key, "field.")
+		if !ok {
+			continue
+		}
+		def, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("неизвестное поле %s", name)
+		}
+		value, err := def.Normalize(values[0])
+		if err != nil {
+			return err
+		}
+		if filter.Fields == nil {
+			filter.Fields = make(map[string]any)
+		}
+		filter.Fields[name] = value
+	}
+
+	if name, ok := strings.CutPrefix(strings.TrimPrefix(sort, "-"), "field."); ok {
+		if _, ok := byName[name]; !ok {
+			return fmt.Errorf("неизвестное поле %s", name)
+		}
+		filter.Sort = sort
+	}
+
+	return nil
+}
+
+func GetFieldsHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	defs, err := storage.GetFieldDefs()
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения пользовательских полей")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]task.FieldDef{"fields": defs})
+}
+
+func AddFieldHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var d task.FieldDef
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+	d.Tag = strings.ToLower(strings.TrimPrefix(d.Tag, "#"))
+	if err := d.Validate(); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	if err := storage.InsertFieldDef(d); err != nil {
+		http.Error(w, `{"error":"Поле с таким именем уже существует"}`, http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{"name": d.Name})
+}
+
+func UpdateFieldHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var d task.FieldDef
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	defs, err := storage.GetFieldDefs()
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения пользовательских полей")
+		return
+	}
+	var current *task.FieldDef
+	for i := range defs {
+		if defs[i].Name == d.Name {
+			current = &defs[i]
+		}
+	}
+	if current == nil {
+		http.Error(w, `{"error":"Поле не найдено"}`, http.StatusNotFound)
+		return
+	}
+	if d.Type != "" && d.Type != current.Type {
+		http.Error(w, `{"error":"Тип поля нельзя изменить"}`, http.StatusBadRequest)
+		return
+	}
+
+	d.Type = current.Type
+	d.Tag = strings.ToLower(strings.TrimPrefix(d.Tag, "#"))
+	if err := d.Validate(); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	if _, err := storage.UpdateFieldDef(d); err != nil {
+		writeStorageError(w, err, "Ошибка обновления поля")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func DeleteFieldHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, `{"error":"Не указано имя поля"}`, http.StatusBadRequest)
+		return
+	}
+
+	rowsAffected, err := storage.DeleteFieldDef(name)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка удаления поля")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Поле не найдено"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/imbalaancing/go_final_project/internal/date"
@@ -12,7 +18,7 @@ import (
 	"github.com/imbalaancing/go_final_project/internal/task"
 )
 
-func GetTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage) {
+func GetTaskHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
@@ -24,16 +30,17 @@ func GetTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage)
 		if err == sql.ErrNoRows {
 			http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
 		} else {
-			http.Error(w, `{"error":"Ошибка получения задачи"}`, http.StatusInternalServerError)
+			writeStorageError(w, err, "Ошибка получения задачи")
 		}
 		return
 	}
 
+	w.Header().Set("ETag", etag(task))
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(task)
 }
 
-func UpdateTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage) {
+func UpdateTaskHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
 	var t task.Task
 	err := json.NewDecoder(r.Body).Decode(&t)
 	if err != nil {
@@ -42,13 +49,34 @@ func UpdateTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Stora
 	}
 
 	if err := t.ValidateTask(); err != nil {
-		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		writeBadRequest(w, err)
 		return
 	}
 
-	rowsAffected, err := storage.UpdateTask(t)
+	defs, err := storage.GetFieldDefs()
 	if err != nil {
-		http.Error(w, `{"error":"Ошибка обновления задачи"}`, http.StatusInternalServerError)
+		writeStorageError(w, err, "Ошибка получения пользовательских полей")
+		return
+	}
+	if err := t.ValidateFields(defs); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	version, ok := checkIfMatch(w, r, storage, t.ID)
+	if !ok {
+		return
+	}
+	t.Version = version
+
+	var steps []db.UndoStep
+	rowsAffected, err := storage.As(author(r)).Recording(&steps).UpdateTask(t)
+	if errors.Is(err, db.ErrVersionConflict) {
+		http.Error(w, `{"error":"Задача изменена или удалена, обновите её и повторите запрос"}`, http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err, "Ошибка обновления задачи")
 		return
 	}
 
@@ -56,15 +84,166 @@ func UpdateTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Stora
 		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
 		return
 	}
+	UndoStack.Push(sessionID(w, r), "update", steps)
+
+	if updated, err := storage.GetTask(t.ID); err == nil {
+		w.Header().Set("ETag", etag(updated))
+	}
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(map[string]string{})
 }
 
-func GetTasksHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage) {
-	tasks, err := storage.GetTasks()
+func GetTasksHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	writeTasks(w, r.URL.Query(), storage)
+}
+
+// taskListParams перечисляет параметры списка задач, которые можно сохранить
+// в представлении.
+var taskListParams = []string{"due_before", "tag", "q", "search", "sort", "group"}
+
+// parseTaskList разбирает параметры списка задач. Строка поиска вида 02.01.2006
+// превращается в отбор по дате, любая другая возвращается для поиска по тексту.
+func parseTaskList(params url.Values, storage db.TaskStore) (db.TaskFilter, string, error) {
+	var filter db.TaskFilter
+	if dueBefore := params.Get("due_before"); dueBefore != "" {
+		if _, err := time.Parse(date.DATE_FORMAT, dueBefore); err != nil {
+			return filter, "", fmt.Errorf("Неверный формат даты due_before")
+		}
+		filter.DueBefore = dueBefore
+	}
+	filter.Tag = strings.ToLower(strings.TrimPrefix(params.Get("tag"), "#"))
+
+	if sort := params.Get("sort"); sort != "" && !strings.HasPrefix(strings.TrimPrefix(sort, "-"), "field.") {
+		if !slices.Contains(db.SortOrders, strings.TrimPrefix(sort, "-")) {
+			return filter, "", fmt.Errorf("Неизвестная сортировка %s", sort)
+		}
+		filter.Sort = sort
+	}
+	if group := params.Get("group"); group != "" && group != "due" {
+		return filter, "", fmt.Errorf("Неизвестная группировка %s", group)
+	}
+
+	if err := parseFieldParams(params, storage, &filter); err != nil {
+		return filter, "", err
+	}
+
+	if q := params.Get("q"); q != "" {
+		query, err := db.ParseQuery(q)
+		if err != nil {
+			return filter, "", err
+		}
+		filter.Query = &query
+	}
+
+	search := strings.TrimSpace(params.Get("search"))
+	if d, err := time.Parse("02.01.2006", search); err == nil {
+		filter.From = d.Format(date.DATE_FORMAT)
+		filter.To = filter.From
+		search = ""
+	}
+	return filter, search, nil
+}
+
+// writeBadRequest отвечает 400 с текстом ошибки, который может содержать
+// кавычки из условия запроса.
+func writeBadRequest(w http.ResponseWriter, err error) {
+	msg, _ := json.Marshal(map[string]string{"error": err.Error()})
+	http.Error(w, string(msg), http.StatusBadRequest)
+}
+
+// writeStorageError отвечает на ошибку хранилища: 504, если запрос к базе не
+// уложился в TODO_QUERY_TIMEOUT, 503, если он отменён, и 500 с текстом msg
+// в остальных случаях.
+func writeStorageError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		http.Error(w, `{"error":"База данных не ответила вовремя, повторите запрос позже"}`, http.StatusGatewayTimeout)
+	case errors.Is(err, context.Canceled):
+		http.Error(w, `{"error":"Запрос к базе данных отменён"}`, http.StatusServiceUnavailable)
+	default:
+		http.Error(w, `{"error":"`+msg+`"}`, http.StatusInternalServerError)
+	}
+}
+
+// parsePage разбирает параметры страницы limit, cursor и total.
+func parsePage(params url.Values) (db.Page, error) {
+	var page db.Page
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return page, fmt.Errorf("Неверное значение limit")
+		}
+		page.Limit = n
+	}
+	if cursor := params.Get("cursor"); cursor != "" {
+		c, err := db.ParseCursor(cursor)
+		if err != nil {
+			return page, err
+		}
+		page.Cursor = c
+	}
+	page.Total = params.Get("total") == "true" || params.Get("total") == "1"
+	return page, nil
+}
+
+// writeTasks отвечает страницей задач, отобранных по параметрам params.
+func writeTasks(w http.ResponseWriter, params url.Values, storage db.TaskStore) {
+	filter, search, err := parseTaskList(params, storage)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	p, err := parsePage(params)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	var page db.TaskPage
+	if search != "" {
+		page, err = storage.SearchTasks(search, filter, p)
+	} else {
+		page, err = storage.GetTaskPage(filter, p)
+	}
+	if errors.Is(err, db.ErrInvalidCursor) {
+		writeBadRequest(w, err)
+		return
+	}
 	if err != nil {
-		http.Error(w, `{"error":"Не удалось запросить задачи"}`, http.StatusInternalServerError)
+		writeStorageError(w, err, "Не удалось запросить задачи")
+		return
+	}
+
+	// С group=due задачи страницы раскладываются по разделам относительно
+	// сегодняшнего дня в часовом поясе сервера. Курсоры и общее число задач
+	// выводятся, только если есть соседняя страница и если число запрошено.
+	resp := map[string]any{}
+	if params.Get("group") == "due" {
+		resp["sections"] = task.Sections(page.Tasks, time.Now())
+	} else {
+		resp["tasks"] = page.Tasks
+	}
+	if page.Next != nil {
+		resp["next_cursor"] = page.Next.String()
+	}
+	if page.Prev != nil {
+		resp["prev_cursor"] = page.Prev.String()
+	}
+	if page.Total >= 0 {
+		resp["total"] = page.Total
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, `{"error":"Не удалось закодировать задачи"}`, http.StatusInternalServerError)
+	}
+}
+
+func GetOverdueTasksHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	tasks, err := storage.GetTasks(db.TaskFilter{Overdue: true})
+	if err != nil {
+		writeStorageError(w, err, "Не удалось запросить задачи")
 		return
 	}
 
@@ -74,7 +253,46 @@ func GetTasksHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage
 	}
 }
 
-func AddTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage) {
+func RescheduleTasksHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var req struct {
+		Filter db.TaskFilter       `json:"filter"`
+		Action db.RescheduleAction `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	if req.Filter.IsEmpty() {
+		http.Error(w, `{"error":"Не указан фильтр задач"}`, http.StatusBadRequest)
+		return
+	}
+	for _, d := range []string{req.Filter.From, req.Filter.To} {
+		if _, err := time.Parse(date.DATE_FORMAT, d); d != "" && err != nil {
+			http.Error(w, `{"error":"Неверный формат даты в фильтре"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	req.Filter.Tag = strings.ToLower(strings.TrimPrefix(req.Filter.Tag, "#"))
+
+	if err := req.Action.Validate(); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	var steps []db.UndoStep
+	result, err := storage.As(author(r)).Recording(&steps).Reschedule(req.Filter, req.Action, time.Now())
+	if err != nil {
+		writeStorageError(w, err, "Ошибка переноса задач")
+		return
+	}
+	UndoStack.Push(sessionID(w, r), "reschedule", steps)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]any{"moved": result.Moved, "count": len(result.Moved), "skipped": result.Skipped})
+}
+
+func AddTaskHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
 	var t task.Task
 	err := json.NewDecoder(r.Body).Decode(&t)
 	if err != nil {
@@ -83,13 +301,23 @@ func AddTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage)
 	}
 
 	if err := t.ValidateTask(); err != nil {
-		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusBadRequest)
+		writeBadRequest(w, err)
 		return
 	}
 
-	id, err := storage.InsertTask(t)
+	defs, err := storage.GetFieldDefs()
 	if err != nil {
-		http.Error(w, `{"error":"Ошибка добавления задачи"}`, http.StatusInternalServerError)
+		writeStorageError(w, err, "Ошибка получения пользовательских полей")
+		return
+	}
+	if err := t.ValidateFields(defs); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	id, err := storage.As(author(r)).InsertTask(t)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка добавления задачи")
 		return
 	}
 
@@ -97,16 +325,26 @@ func AddTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage)
 	json.NewEncoder(w).Encode(map[string]string{"id": strconv.FormatInt(id, 10)})
 }
 
-func DeleteTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage) {
+func DeleteTaskHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
 		return
 	}
 
-	rowsAffected, err := storage.DeleteTask(id)
+	version, ok := checkIfMatch(w, r, storage, id)
+	if !ok {
+		return
+	}
+
+	var steps []db.UndoStep
+	rowsAffected, err := storage.As(author(r)).Recording(&steps).DeleteTask(id, version)
+	if errors.Is(err, db.ErrVersionConflict) {
+		http.Error(w, `{"error":"Задача изменена или удалена, обновите её и повторите запрос"}`, http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
-		http.Error(w, `{"error":"Ошибка удаления задачи"}`, http.StatusInternalServerError)
+		writeStorageError(w, err, "Ошибка удаления задачи")
 		return
 	}
 
@@ -114,22 +352,35 @@ func DeleteTaskHandler(w http.ResponseWriter, r *http.Request, storage *db.Stora
 		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
 		return
 	}
+	UndoStack.Push(sessionID(w, r), "delete", steps)
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(map[string]string{})
 }
 
-func MarkTaskDoneHandler(w http.ResponseWriter, r *http.Request, storage *db.Storage) {
+func MarkTaskDoneHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
 	id := r.URL.Query().Get("id")
 	if id == "" {
 		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
 		return
 	}
 
-	if err := storage.MarkTaskDone(id); err != nil {
-		http.Error(w, `{"error":"Ошибка отметки выполнения задачи"}`, http.StatusInternalServerError)
+	version, ok := checkIfMatch(w, r, storage, id)
+	if !ok {
+		return
+	}
+
+	var steps []db.UndoStep
+	err := storage.As(author(r)).Recording(&steps).MarkTaskDone(id, version)
+	if errors.Is(err, db.ErrVersionConflict) {
+		http.Error(w, `{"error":"Задача изменена или удалена, обновите её и повторите запрос"}`, http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err, "Ошибка отметки выполнения задачи")
 		return
 	}
+	UndoStack.Push(sessionID(w, r), "done", steps)
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	json.NewEncoder(w).Encode(map[string]string{})
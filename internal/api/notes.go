@@ -0,0 +1,153 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/markdown"
+)
+
+func decodeNoteBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return "", false
+	}
+	if strings.TrimSpace(req.Body) == "" {
+		http.Error(w, `{"error":"Не указан текст заметки"}`, http.StatusBadRequest)
+		return "", false
+	}
+	return req.Body, true
+}
+
+func renderNote(n *db.Note) error {
+	html, err := markdown.ToHTML(n.Body)
+	n.HTML = html
+	return err
+}
+
+func AddNoteHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+	body, ok := decodeNoteBody(w, r)
+	if !ok {
+		return
+	}
+
+	noteID, err := storage.InsertNote(id, body)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка добавления заметки")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{"id": strconv.FormatInt(noteID, 10)})
+}
+
+func GetNotesHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	notes, err := storage.GetNotes(id)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения заметок")
+		return
+	}
+	for i := range notes {
+		if err := renderNote(&notes[i]); err != nil {
+			http.Error(w, `{"error":"Ошибка обработки Markdown"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]db.Note{"notes": notes})
+}
+
+func GetNoteHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	note, err := storage.GetNote(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Заметка не найдена"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка получения заметки")
+		}
+		return
+	}
+	if err := renderNote(&note); err != nil {
+		http.Error(w, `{"error":"Ошибка обработки Markdown"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(note)
+}
+
+func UpdateNoteHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+	body, ok := decodeNoteBody(w, r)
+	if !ok {
+		return
+	}
+
+	rowsAffected, err := storage.UpdateNote(id, body)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка обновления заметки")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Заметка не найдена"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func DeleteNoteHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	rowsAffected, err := storage.DeleteNote(id)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка удаления заметки")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Заметка не найдена"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
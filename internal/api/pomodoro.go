@@ -0,0 +1,144 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/pomodoro"
+)
+
+func StartPomodoroHandler(w http.ResponseWriter, r *http.Request, manager *pomodoro.Manager) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	settings := pomodoro.DefaultSettings
+	for _, p := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"focus", &settings.Focus},
+		{"short_break", &settings.ShortBreak},
+		{"long_break", &settings.LongBreak},
+	} {
+		raw := r.URL.Query().Get(p.name)
+		if raw == "" {
+			continue
+		}
+		minutes, err := strconv.Atoi(raw)
+		if err != nil || minutes < 1 || minutes > 240 {
+			http.Error(w, fmt.Sprintf(`{"error":"Неверная длительность %s"}`, p.name), http.StatusBadRequest)
+			return
+		}
+		*p.value = time.Duration(minutes) * time.Minute
+	}
+
+	state, err := manager.Start(id, settings)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, pomodoro.ErrSessionActive):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case err != nil:
+		writeStorageError(w, err, "Ошибка запуска сессии")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(state)
+}
+
+func StopPomodoroHandler(w http.ResponseWriter, r *http.Request, manager *pomodoro.Manager) {
+	state, err := manager.Stop()
+	if err != nil {
+		writeStorageError(w, err, "Ошибка остановки сессии")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(state)
+}
+
+func GetPomodoroHandler(w http.ResponseWriter, r *http.Request, manager *pomodoro.Manager) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(manager.State())
+}
+
+// PomodoroEventsHandler отправляет клиенту состояние сессии как server-sent events:
+// сразу после подключения и при каждом изменении.
+func PomodoroEventsHandler(w http.ResponseWriter, r *http.Request, manager *pomodoro.Manager) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, `{"error":"Потоковая передача не поддерживается"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	updates := manager.Subscribe()
+	defer manager.Unsubscribe(updates)
+
+	keepAlive := time.NewTicker(30 * time.Second)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case state := <-updates:
+			data, err := json.Marshal(state)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: pomodoro\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
+
+func FocusSummaryHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	day := time.Now()
+	if raw := r.URL.Query().Get("date"); raw != "" {
+		parsed, err := time.ParseInLocation(date.DATE_FORMAT, raw, time.Local)
+		if err != nil {
+			http.Error(w, `{"error":"Неверный формат даты"}`, http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+
+	summary, err := storage.FocusSummary(day)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка построения сводки")
+		return
+	}
+
+	sessions, minutes := 0, 0
+	for _, row := range summary {
+		sessions += row.Sessions
+		minutes += row.Minutes
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]any{
+		"date":     day.Format(date.DATE_FORMAT),
+		"sessions": sessions,
+		"minutes":  minutes,
+		"tasks":    summary,
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/quickadd"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+type quickAddRequest struct {
+	Text string `json:"text"`
+}
+
+type quickAddResponse struct {
+	ID     string              `json:"id"`
+	Task   task.Task           `json:"task"`
+	Parsed []quickadd.Fragment `json:"parsed"`
+}
+
+func QuickAddTaskHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var req quickAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	result, err := quickadd.Parse(req.Text, time.Now())
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	t := result.Task
+	if err := t.ValidateTask(); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	id, err := storage.As(author(r)).InsertTask(t)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка добавления задачи")
+		return
+	}
+	t.ID = strconv.FormatInt(id, 10)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(quickAddResponse{ID: t.ID, Task: t, Parsed: result.Fragments})
+}
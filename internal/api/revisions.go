@@ -0,0 +1,62 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+// author определяет, кто вносит изменение: по заголовку X-User, а если его нет —
+// по адресу клиента.
+func author(r *http.Request) string {
+	if user := r.Header.Get("X-User"); user != "" {
+		return user
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func GetRevisionsHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := storage.GetRevisions(id)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения истории задачи")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]db.Revision{"revisions": revisions})
+}
+
+func RevertTaskHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор ревизии"}`, http.StatusBadRequest)
+		return
+	}
+
+	t, err := storage.As(author(r)).RevertTask(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Ревизия не найдена"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка восстановления задачи")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(t)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/templates"
+)
+
+func GetTemplatesHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	list, err := storage.GetTemplates()
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения шаблонов")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]templates.Template{"templates": list})
+}
+
+func AddTemplateHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var t templates.Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if err := t.Validate(); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	id, err := storage.InsertTemplate(t)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка добавления шаблона")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{"id": strconv.FormatInt(id, 10)})
+}
+
+func GetTemplateHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	t, err := storage.GetTemplate(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Шаблон не найден"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка получения шаблона")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]any{"template": t, "placeholders": t.Placeholders()})
+}
+
+func UpdateTemplateHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var t templates.Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if err := t.Validate(); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	rowsAffected, err := storage.UpdateTemplate(t)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка обновления шаблона")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Шаблон не найден"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func DeleteTemplateHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	rowsAffected, err := storage.DeleteTemplate(id)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка удаления шаблона")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Шаблон не найден"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func InstantiateTemplateHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Params map[string]string `json:"params"`
+		Start  string            `json:"start"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	if req.Start != "" {
+		parsed, err := time.Parse(date.DATE_FORMAT, req.Start)
+		if err != nil {
+			http.Error(w, `{"error":"Неверный формат даты начала"}`, http.StatusBadRequest)
+			return
+		}
+		start = parsed
+	}
+
+	t, err := storage.GetTemplate(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Шаблон не найден"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка получения шаблона")
+		}
+		return
+	}
+
+	tasks, err := t.Instantiate(req.Params, start)
+	if err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+	for i := range tasks {
+		if err := tasks[i].ValidateTask(); err != nil {
+			writeBadRequest(w, err)
+			return
+		}
+	}
+
+	var steps []db.UndoStep
+	ids, err := storage.As(author(r)).Recording(&steps).InsertTasks(tasks)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка создания задач")
+		return
+	}
+	UndoStack.Push(sessionID(w, r), "instantiate", steps)
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]string{"ids": ids})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+func StartTimerHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := storage.StartTimer(id, time.Now())
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, `{"error":"Задача не найдена"}`, http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrTimerRunning):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case err != nil:
+		writeStorageError(w, err, "Ошибка запуска таймера")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func StopTimerHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, `{"error":"Не указан идентификатор"}`, http.StatusBadRequest)
+		return
+	}
+
+	err := storage.StopTimer(id, time.Now())
+	switch {
+	case errors.Is(err, db.ErrTimerNotRunning):
+		http.Error(w, `{"error":"`+err.Error()+`"}`, http.StatusConflict)
+		return
+	case err != nil:
+		writeStorageError(w, err, "Ошибка остановки таймера")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func TimeReportHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	groupBy := r.URL.Query().Get("by")
+	if groupBy == "" {
+		groupBy = db.ReportByDay
+	}
+	if groupBy != db.ReportByDay && groupBy != db.ReportByWeek && groupBy != db.ReportByTag {
+		http.Error(w, `{"error":"Неподдерживаемая группировка отчёта"}`, http.StatusBadRequest)
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	to := r.URL.Query().Get("to")
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse(date.DATE_FORMAT, d); d != "" && err != nil {
+			http.Error(w, `{"error":"Неверный формат даты"}`, http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := storage.TimeReport(groupBy, from, to, time.Now())
+	if err != nil {
+		writeStorageError(w, err, "Ошибка построения отчёта")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]any{"by": groupBy, "report": report})
+}
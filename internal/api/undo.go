@@ -0,0 +1,69 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/undo"
+)
+
+const sessionCookie = "session"
+
+// UndoStack хранит отменяемые операции по сессиям.
+var UndoStack = undo.NewStack(undo.DefaultTTL)
+
+// sessionID возвращает идентификатор сессии из заголовка X-Session или cookie,
+// а если его нет — выдаёт клиенту новый.
+func sessionID(w http.ResponseWriter, r *http.Request) string {
+	if id := r.Header.Get("X-Session"); id != "" {
+		return id
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		return c.Value
+	}
+
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	r.AddCookie(&http.Cookie{Name: sessionCookie, Value: id})
+	return id
+}
+
+func UndoHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	session := sessionID(w, r)
+	entry, ok := UndoStack.Pop(session)
+	if !ok {
+		http.Error(w, `{"error":"Нет операций для отмены"}`, http.StatusNotFound)
+		return
+	}
+
+	err := storage.As(author(r)).Undo(entry.Steps)
+	if errors.Is(err, db.ErrVersionConflict) {
+		http.Error(w, `{"error":"Задачи изменены после операции, отменить её нельзя"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		UndoStack.Return(session, entry)
+		writeStorageError(w, err, "Ошибка отмены операции")
+		return
+	}
+
+	ids := make([]string, 0, len(entry.Steps))
+	for _, step := range entry.Steps {
+		ids = append(ids, step.TaskID)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]any{"undone": entry.Op, "tasks": ids})
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+// viewQuery превращает параметры представления в параметры списка задач.
+func viewQuery(v db.View) url.Values {
+	params := make(url.Values, len(v.Params))
+	for key, value := range v.Params {
+		params.Set(key, value)
+	}
+	return params
+}
+
+// validateView проверяет название и параметры представления так же, как их
+// проверил бы GET /api/tasks.
+func validateView(v db.View, storage db.TaskStore) error {
+	if strings.TrimSpace(v.Name) == "" {
+		return fmt.Errorf("не указано название представления")
+	}
+	for key := range v.Params {
+		if !slices.Contains(taskListParams, key) && !strings.HasPrefix(key, "field.") {
+			return fmt.Errorf("неизвестный параметр %s", key)
+		}
+	}
+	_, _, err := parseTaskList(viewQuery(v), storage)
+	return err
+}
+
+func GetViewsHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	list, err := storage.GetViews()
+	if err != nil {
+		writeStorageError(w, err, "Ошибка получения представлений")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string][]db.View{"views": list})
+}
+
+func AddViewHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var v db.View
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+	if err := validateView(v, storage); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	id, err := storage.InsertView(v)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка добавления представления")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{"id": strconv.FormatInt(id, 10)})
+}
+
+func GetViewHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	v, err := storage.GetView(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Представление не найдено"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка получения представления")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(v)
+}
+
+func UpdateViewHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	var v db.View
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, `{"error":"Ошибка десериализации JSON"}`, http.StatusBadRequest)
+		return
+	}
+	v.ID = r.PathValue("id")
+	if err := validateView(v, storage); err != nil {
+		writeBadRequest(w, err)
+		return
+	}
+
+	rowsAffected, err := storage.UpdateView(v)
+	if err != nil {
+		writeStorageError(w, err, "Ошибка обновления представления")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Представление не найдено"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+func DeleteViewHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	rowsAffected, err := storage.DeleteView(r.PathValue("id"))
+	if err != nil {
+		writeStorageError(w, err, "Ошибка удаления представления")
+		return
+	}
+	if rowsAffected == 0 {
+		http.Error(w, `{"error":"Представление не найдено"}`, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(map[string]string{})
+}
+
+// GetViewTasksHandler отвечает так же, как GET /api/tasks с параметрами
+// представления. Параметры запроса, которых нет в представлении, добавляются
+// к ним.
+func GetViewTasksHandler(w http.ResponseWriter, r *http.Request, storage db.TaskStore) {
+	v, err := storage.GetView(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, `{"error":"Представление не найдено"}`, http.StatusNotFound)
+		} else {
+			writeStorageError(w, err, "Ошибка получения представления")
+		}
+		return
+	}
+
+	params := viewQuery(v)
+	for key, values := range r.URL.Query() {
+		if !params.Has(key) {
+			params[key] = values
+		}
+	}
+	writeTasks(w, params, storage)
+}
@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+// Копии называются scheduler-ГГГГММДД-ЧЧММСС.ммм.db по времени UTC, поэтому
+// по имени они упорядочены по времени создания.
+const (
+	filePrefix = "scheduler-"
+	fileExt    = ".db"
+	timeLayout = "20060102-150405.000"
+)
+
+// DefaultKeep — сколько последних копий хранится по умолчанию.
+const DefaultKeep = 7
+
+// File — копия базы в каталоге копий.
+type File struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Backups создаёт копии базы SQLite в каталоге Dir и хранит из них Keep
+// последних.
+type Backups struct {
+	db   *sql.DB
+	Dir  string
+	Keep int
+	// mu не даёт двум копиям создаваться одновременно.
+	mu sync.Mutex
+}
+
+func New(database *sql.DB, dir string, keep int) *Backups {
+	return &Backups{db: database, Dir: dir, Keep: keep}
+}
+
+// Create сохраняет копию базы и удаляет копии сверх Keep.
+func (b *Backups) Create(ctx context.Context) (File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := b.create(ctx)
+	if err != nil {
+		return File{}, err
+	}
+	b.rotate()
+	return f, nil
+}
+
+// Restore сохраняет копию текущей базы и заменяет базу копией из файла path.
+// Старые копии удаляются только после восстановления, чтобы не удалить path,
+// если он лежит в каталоге копий. Возвращается копия базы до восстановления.
+func (b *Backups) Restore(ctx context.Context, path string) (File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	f, err := b.create(ctx)
+	if err != nil {
+		return File{}, err
+	}
+	if err := db.Restore(ctx, b.db, path); err != nil {
+		return f, err
+	}
+	b.rotate()
+	return f, nil
+}
+
+func (b *Backups) create(ctx context.Context) (File, error) {
+	if err := os.MkdirAll(b.Dir, 0o755); err != nil {
+		return File{}, err
+	}
+	now := time.Now().UTC()
+	name := filePrefix + now.Format(timeLayout) + fileExt
+	path := filepath.Join(b.Dir, name)
+	if err := db.Backup(ctx, b.db, path); err != nil {
+		return File{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+	return File{Name: name, Size: info.Size(), CreatedAt: now.Truncate(time.Millisecond)}, nil
+}
+
+// List возвращает копии из каталога, начиная с самой новой.
+func (b *Backups) List() ([]File, error) {
+	entries, err := os.ReadDir(b.Dir)
+	if os.IsNotExist(err) {
+		return []File{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]File, 0, len(entries))
+	for _, e := range entries {
+		created, ok := parseName(e.Name())
+		if !ok || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: e.Name(), Size: info.Size(), CreatedAt: created})
+	}
+	slices.SortFunc(files, func(a, b File) int { return strings.Compare(b.Name, a.Name) })
+	return files, nil
+}
+
+// rotate удаляет копии, которые старше Keep последних. Ошибка удаления
+// только записывается в лог: новая копия уже сохранена.
+func (b *Backups) rotate() {
+	files, err := b.List()
+	if err != nil {
+		log.Printf("Ошибка удаления старых копий базы: %v", err)
+		return
+	}
+	if len(files) <= b.Keep {
+		return
+	}
+	for _, f := range files[b.Keep:] {
+		if err := os.Remove(filepath.Join(b.Dir, f.Name)); err != nil {
+			log.Printf("Ошибка удаления старых копий базы: %v", err)
+			return
+		}
+		log.Printf("Удалена старая копия базы %s.", f.Name)
+	}
+}
+
+// Start создаёт копию каждые interval. Если последняя копия старше interval
+// или копий нет, первая создаётся сразу.
+func (b *Backups) Start(interval time.Duration) {
+	go func() {
+		wait := time.Duration(0)
+		if files, err := b.List(); err == nil && len(files) > 0 {
+			wait = max(0, interval-time.Since(files[0].CreatedAt))
+		}
+		for {
+			time.Sleep(wait)
+			f, err := b.Create(context.Background())
+			if err != nil {
+				log.Printf("Ошибка резервного копирования базы: %v", err)
+			} else {
+				log.Printf("Создана копия базы %s (%d байт).", f.Name, f.Size)
+			}
+			wait = interval
+		}
+	}()
+}
+
+func parseName(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, fileExt)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(timeLayout, stamp)
+	return t, err == nil
+}
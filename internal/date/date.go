@@ -43,7 +43,108 @@ func NextDate(now time.Time, date string, repeat string) (string, error) {
 		}
 		return currDate.Format(DATE_FORMAT), nil
 
+	case "w":
+		if len(parts) != 2 {
+			return "", fmt.Errorf("не указаны дни недели")
+		}
+		weekdays, err := parseList(parts[1], 1, 7)
+		if err != nil {
+			return "", fmt.Errorf("неверный формат дней недели: %v", err)
+		}
+		return nextMatching(now, startDate, func(d time.Time) bool {
+			weekday := int(d.Weekday())
+			if weekday == 0 {
+				weekday = 7
+			}
+			return weekdays[weekday]
+		})
+
+	case "m":
+		if len(parts) < 2 || len(parts) > 3 {
+			return "", fmt.Errorf("не указаны дни месяца")
+		}
+		days, err := parseList(parts[1], -2, 31)
+		if err == nil && days[0] {
+			err = fmt.Errorf("день 0 не существует")
+		}
+		if err != nil {
+			return "", fmt.Errorf("неверный формат дней месяца: %v", err)
+		}
+		months := make(map[int]bool)
+		if len(parts) == 3 {
+			if months, err = parseList(parts[2], 1, 12); err != nil {
+				return "", fmt.Errorf("неверный формат месяцев: %v", err)
+			}
+		}
+		return nextMatching(now, startDate, func(d time.Time) bool {
+			if len(months) > 0 && !months[int(d.Month())] {
+				return false
+			}
+			// Отрицательные дни отсчитываются от конца месяца: -1 — последний день.
+			last := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+			return days[d.Day()] || days[d.Day()-last-1]
+		})
+
 	default:
 		return "", fmt.Errorf("неподдерживаемый формат %s", param)
 	}
 }
+
+// parseList разбирает список чисел через запятую из диапазона [min, max].
+func parseList(s string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, err
+		}
+		if n < min || n > max {
+			return nil, fmt.Errorf("значение %d вне диапазона", n)
+		}
+		values[n] = true
+	}
+	return values, nil
+}
+
+// nextMatching ищет первую подходящую дату строго после сегодняшней и после start.
+func nextMatching(now, start time.Time, match func(time.Time) bool) (string, error) {
+	d := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if start.After(d) {
+		d = start
+	}
+	// За 10 лет встретится любое допустимое сочетание дня и месяца.
+	for i := 0; i < 3660; i++ {
+		d = d.AddDate(0, 0, 1)
+		if match(d) {
+			return d.Format(DATE_FORMAT), nil
+		}
+	}
+	return "", fmt.Errorf("правило не задаёт ни одной даты")
+}
+
+// Shift сдвигает дату value на столько же дней, на сколько from отстоит от to.
+func Shift(value, from, to string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	f, err := time.Parse(DATE_FORMAT, from)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат даты: %v", err)
+	}
+	t, err := time.Parse(DATE_FORMAT, to)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат даты: %v", err)
+	}
+
+	return AddDays(value, int(t.Sub(f).Hours()/24))
+}
+
+// AddDays прибавляет к дате value указанное число дней.
+func AddDays(value string, days int) (string, error) {
+	v, err := time.Parse(DATE_FORMAT, value)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат даты: %v", err)
+	}
+	return v.AddDate(0, 0, days).Format(DATE_FORMAT), nil
+}
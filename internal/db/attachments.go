@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Attachment — файл, прикреплённый к задаче. Содержимое хранится либо в каталоге
+// вложений (Path), либо прямо в базе.
+type Attachment struct {
+	ID          int64     `json:"id"`
+	TaskID      string    `json:"task_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+	Path        string    `json:"-"`
+}
+
+// SetAttachmentsDir включает хранение вложений в каталоге dir вместо базы.
+func (s *Storage) SetAttachmentsDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	s.attachmentsDir = dir
+	return nil
+}
+
+func (s *Storage) InsertAttachment(a Attachment, content []byte) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT id FROM scheduler WHERE id = ?`, a.TaskID).Scan(new(int64)); err != nil {
+		return 0, err
+	}
+
+	var data []byte
+	if s.attachmentsDir == "" {
+		data = content
+	}
+	var id int64
+	err = tx.QueryRow(`INSERT INTO attachments (task_id, name, content_type, size, data, created_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id`,
+		a.TaskID, a.Name, a.ContentType, len(content), data, time.Now().UTC().Format(time.RFC3339)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.attachmentsDir != "" {
+		path := filepath.Join(s.attachmentsDir, fmt.Sprintf("%s-%d", a.TaskID, id))
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE attachments SET path = ? WHERE id = ?`, path, id); err != nil {
+			removeFiles([]string{path})
+			return 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			removeFiles([]string{path})
+			return 0, err
+		}
+		return id, nil
+	}
+
+	return id, tx.Commit()
+}
+
+func (s *Storage) GetAttachments(taskID string) ([]Attachment, error) {
+	rows, err := s.db.Query(`SELECT id, task_id, name, content_type, size, path, created_at
+FROM attachments WHERE task_id = ?`+liveTask+` ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]Attachment, 0)
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// GetAttachment возвращает описание вложения и его содержимое.
+func (s *Storage) GetAttachment(id string) (Attachment, []byte, error) {
+	var data []byte
+	a, err := scanAttachment(s.db.QueryRow(`SELECT id, task_id, name, content_type, size, path, created_at
+FROM attachments WHERE id = ?`+liveTask, id))
+	if err != nil {
+		return a, nil, err
+	}
+
+	if a.Path != "" {
+		data, err = os.ReadFile(a.Path)
+	} else {
+		err = s.db.QueryRow(`SELECT data FROM attachments WHERE id = ?`, id).Scan(&data)
+	}
+	return a, data, err
+}
+
+func (s *Storage) DeleteAttachment(id string) (int64, error) {
+	var path string
+	err := s.db.QueryRow(`SELECT path FROM attachments WHERE id = ?`+liveTask, id).Scan(&path)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.db.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	removeFiles([]string{path})
+	return res.RowsAffected()
+}
+
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var a Attachment
+	var created string
+	if err := row.Scan(&a.ID, &a.TaskID, &a.Name, &a.ContentType, &a.Size, &a.Path, &created); err != nil {
+		return a, err
+	}
+	var err error
+	a.CreatedAt, err = time.Parse(time.RFC3339, created)
+	return a, err
+}
+
+func attachmentFiles(tx *sqlTx, taskID string) ([]string, error) {
+	rows, err := tx.Query(`SELECT path FROM attachments WHERE task_id = ? AND path != ''`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+	return files, rows.Err()
+}
+
+func removeFiles(files []string) {
+	for _, f := range files {
+		if f == "" {
+			continue
+		}
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			log.Printf("Не удалось удалить файл вложения %s: %v", f, err)
+		}
+	}
+}
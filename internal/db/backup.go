@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotBackup означает, что файл не является копией базы планировщика.
+var ErrNotBackup = errors.New("файл не является копией базы планировщика")
+
+// Backup сохраняет копию базы SQLite в файл path, не останавливая работу с
+// ней: VACUUM INTO читает базу в одной транзакции чтения, и в режиме WAL
+// запись в это время продолжается. Копия пишется во временный файл и
+// появляется под именем path, только когда сохранена на диске целиком.
+func Backup(ctx context.Context, db *sql.DB, path string) (err error) {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		return err
+	}
+	f, err := os.Open(tmp)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// CheckBackup проверяет копию базы перед восстановлением: целостность файла
+// и версию схемы, которую должен знать этот сервер. Возвращается версия схемы
+// копии; 0 — копия базы, созданной до появления миграций.
+func CheckBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrNotBackup, err)
+	}
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrNotBackup, err)
+	}
+	if len(problems) > 0 {
+		return 0, fmt.Errorf("копия повреждена: %s", strings.Join(problems, "; "))
+	}
+
+	var tables int
+	err = db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name IN ('scheduler', 'schema_migrations')`).
+		Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	switch tables {
+	case 0:
+		return 0, ErrNotBackup
+	case 1:
+		// Только scheduler: база до появления миграций, их применит Restore.
+		return 0, nil
+	}
+
+	migrations, err := loadMigrations("migrations/sqlite")
+	if err != nil {
+		return 0, err
+	}
+	var version int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	if version > len(migrations) {
+		return 0, fmt.Errorf("%w: версия схемы копии %d, поддерживается до %d", ErrSchemaTooNew, version, len(migrations))
+	}
+	return version, nil
+}
+
+// Restore проверяет копию path через CheckBackup и заменяет ею содержимое
+// базы db, после чего применяет к ней недостающие миграции. Страницы
+// переносятся онлайн-копированием SQLite под блокировкой записи, поэтому
+// другие соединения видят базу либо до восстановления, либо после.
+func Restore(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := CheckBackup(path); err != nil {
+		return err
+	}
+	if err := restorePages(ctx, db, path); err != nil {
+		return err
+	}
+
+	migrator, err := NewSQLiteMigrator(db)
+	if err != nil {
+		return err
+	}
+	if _, err := migrator.Up(); err != nil {
+		return err
+	}
+	return initSearch(db)
+}
@@ -0,0 +1,56 @@
+//go:build cgo
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// restorePages копирует все страницы базы из файла path в db через
+// онлайн-копирование SQLite. Пока база занята, копирование повторяется.
+func restorePages(ctx context.Context, db *sql.DB, path string) error {
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	dstConn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	return dstConn.Raw(func(dst any) error {
+		return srcConn.Raw(func(src any) error {
+			b, err := dst.(*sqlite3.SQLiteConn).Backup("main", src.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			for attempt := 0; ; attempt++ {
+				done, err := b.Step(-1)
+				if err != nil || done {
+					if finishErr := b.Finish(); err == nil {
+						err = finishErr
+					}
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					b.Finish()
+					return ctx.Err()
+				case <-time.After(busyBackoff << min(attempt, busyRetries)):
+				}
+			}
+		})
+	})
+}
@@ -0,0 +1,14 @@
+//go:build !cgo
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// Без cgo драйвер SQLite не работает, и восстанавливать некуда.
+func restorePages(ctx context.Context, db *sql.DB, path string) error {
+	return errors.New("восстановление SQLite требует сборки с cgo")
+}
@@ -0,0 +1,86 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// TestSQLiteBackup сохраняет копию работающей базы, меняет базу и
+// восстанавливает её из копии через то же открытое хранилище.
+func TestSQLiteBackup(t *testing.T) {
+	skipWithoutSQLite(t)
+	dir := t.TempDir()
+	database, err := db.InitDB(filepath.Join(dir, "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	s := db.NewTaskStorage(database)
+
+	id, err := s.InsertTask(task.Task{Date: "20240101", Title: "До копии"})
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "backup.db")
+	require.NoError(t, db.Backup(context.Background(), database, path))
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	migrator, err := db.NewSQLiteMigrator(database)
+	require.NoError(t, err)
+	version, err := db.CheckBackup(path)
+	require.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), version)
+
+	_, err = s.InsertTask(task.Task{Date: "20240102", Title: "После копии"})
+	require.NoError(t, err)
+	_, err = s.DeleteTask(strconv.FormatInt(id, 10), 0)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Restore(context.Background(), database, path))
+	tasks, err := s.GetTasks(db.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, "До копии", tasks[0].Title)
+}
+
+func TestCheckBackupRejects(t *testing.T) {
+	skipWithoutSQLite(t)
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	require.NoError(t, os.WriteFile(garbage, []byte("это не база SQLite, а просто текст достаточной длины"), 0o644))
+	_, err := db.CheckBackup(garbage)
+	assert.ErrorIs(t, err, db.ErrNotBackup)
+
+	empty := filepath.Join(dir, "empty.db")
+	database, err := db.OpenDB(empty)
+	require.NoError(t, err)
+	_, err = database.Exec(`CREATE TABLE other (id INTEGER)`)
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+	_, err = db.CheckBackup(empty)
+	assert.ErrorIs(t, err, db.ErrNotBackup)
+
+	newer := filepath.Join(dir, "newer.db")
+	database, err = db.InitDB(newer)
+	require.NoError(t, err)
+	_, err = database.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (1000, '')`)
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+	_, err = db.CheckBackup(newer)
+	assert.ErrorIs(t, err, db.ErrSchemaTooNew)
+
+	// Отклонённая копия не трогает базу.
+	target, err := db.InitDB(filepath.Join(dir, "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { target.Close() })
+	assert.Error(t, db.Restore(context.Background(), target, newer))
+	assert.Error(t, db.Restore(context.Background(), target, filepath.Join(dir, "missing.db")))
+}
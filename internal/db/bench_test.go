@@ -0,0 +1,79 @@
+package db_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Бенчмарки хранилища SQLite: последовательные запросы и параллельные, как
+// при одновременной работе веб-интерфейса и API.
+//
+//	go test ./internal/db -run '^$' -bench . -benchtime 2s
+
+func newBenchStore(b *testing.B, tasks int) db.TaskStore {
+	skipWithoutSQLite(b)
+	database, err := db.InitDB(filepath.Join(b.TempDir(), "scheduler.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { database.Close() })
+	s := db.NewTaskStorage(database)
+
+	list := make([]task.Task, tasks)
+	for i := range list {
+		list[i] = task.Task{Date: days(i % 60), Title: "Задача " + strconv.Itoa(i), Tags: []string{"work"}}
+	}
+	if _, err := s.InsertTasks(list); err != nil {
+		b.Fatal(err)
+	}
+	return s
+}
+
+func BenchmarkGetTasks(b *testing.B) {
+	s := newBenchStore(b, 1000)
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.GetTasks(db.TaskFilter{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := s.GetTasks(db.TaskFilter{}); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkInsertTask(b *testing.B) {
+	s := newBenchStore(b, 0)
+	t := task.Task{Date: today(), Title: "Новая задача", Tags: []string{"work"}, Priority: task.PriorityLow}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.InsertTask(t); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := s.InsertTask(t); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		})
+	})
+}
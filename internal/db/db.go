@@ -1,39 +1,136 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/imbalaancing/go_final_project/internal/date"
 	"github.com/imbalaancing/go_final_project/internal/task"
-	_ "github.com/mattn/go-sqlite3"
 )
 
 const dbFileName = "scheduler.db"
 const TaskLimit = 50
 
-const createTableQuery = `
-CREATE TABLE IF NOT EXISTS scheduler (
-	id INTEGER PRIMARY KEY AUTOINCREMENT,
-	date TEXT NOT NULL,
-	title TEXT NOT NULL,
-	comment TEXT,
-	repeat TEXT
-);
-CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
-`
+// Удаление задачи убирает только её строку из scheduler. Остальные данные
+// задачи остаются под её идентификатором, который не выдаётся повторно:
+// отмена удаления или возврат к ревизии возвращают задачу вместе с ними.
+// Заметки, вложения и значения полей удалённой задачи скрыты и удаляются
+// через PurgeDeleted, а учтённое время, фокус-сессии, оценка и метки
+// хранятся как история для отчётов.
+
+// purgedTables перечисляет таблицы, которые PurgeDeleted очищает от данных
+// давно удалённых задач.
+var purgedTables = []string{"attachments", "task_notes", "task_fields"}
+
+// liveTask отбирает строки задач, которые не удалены.
+const liveTask = ` AND task_id IN (SELECT id FROM scheduler)`
+
+const taskColumns = `s.id, s.date, s.title, s.comment, s.repeat, COALESCE(m.deadline, ''), COALESCE(m.estimate, 0), COALESCE(m.priority, 0), COALESCE(m.version, 0),
+	COALESCE((SELECT GROUP_CONCAT(tt.tag, ',') FROM task_tags tt WHERE tt.task_id = s.id), ''),
+	COALESCE((SELECT json_group_object(tf.name, json(tf.value)) FROM task_fields tf WHERE tf.task_id = s.id), '{}')`
+
+const selectTaskQuery = `SELECT ` + taskColumns + `
+FROM scheduler s LEFT JOIN task_meta m ON m.task_id = s.id`
+
+// upsertMetaQuery увеличивает версию задачи, только если она равна
+// ожидаемой; ожидаемая версия 0 означает запись без проверки.
+const upsertMetaQuery = `INSERT INTO task_meta (task_id, deadline, estimate, priority) VALUES (?, ?, ?, ?)
+ON CONFLICT(task_id) DO UPDATE SET deadline = excluded.deadline, estimate = excluded.estimate, priority = excluded.priority,
+	version = task_meta.version + 1
+WHERE ? = 0 OR task_meta.version = ?`
+
+const upsertDeadlineQuery = `INSERT INTO task_meta (task_id, deadline) VALUES (?, ?)
+ON CONFLICT(task_id) DO UPDATE SET deadline = excluded.deadline, version = task_meta.version + 1
+WHERE ? = 0 OR task_meta.version = ?`
+
+// bumpVersionQuery увеличивает версию задачи перед удалением: строка task_meta
+// остаётся, и у восстановленной задачи версия продолжает расти. Если ожидаемая
+// версия не 0, версия меняется, только пока равна ей.
+const bumpVersionQuery = `UPDATE task_meta SET version = version + 1 WHERE task_id = ? AND (? = 0 OR version = ?)`
+
+// ErrVersionConflict означает, что задачу изменили после того, как была
+// прочитана версия, переданная в UpdateTask, MarkTaskDone или DeleteTask.
+var ErrVersionConflict = errors.New("задача изменена другим запросом")
 
 type Storage struct {
-	db *sql.DB
+	db             *sqlDB
+	attachmentsDir string
+	author         string
+	undo           *[]UndoStep
+}
+
+// DateChange описывает перенос задачи на другую дату. Deadline заполняется
+// только у задач, которые массовый перенос пропустил из-за крайнего срока.
+type DateChange struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	OldDate  string `json:"old_date"`
+	NewDate  string `json:"new_date"`
+	Deadline string `json:"deadline,omitempty"`
 }
 
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// NewTaskStorage возвращает хранилище в базе SQLite, которое готовит запросы
+// один раз и повторяет их, пока база занята.
 func NewTaskStorage(db *sql.DB) *Storage {
-	return &Storage{db: db}
+	return &Storage{db: &sqlDB{DB: db, dialect: sqliteDialect, stmts: newStmtCache(db)}}
+}
+
+// As возвращает хранилище, которое записывает изменения задач от имени author.
+func (s *Storage) As(author string) TaskStore {
+	return s.as(author)
 }
 
+func (s *Storage) as(author string) *Storage {
+	c := *s
+	c.author = author
+	return &c
+}
+
+// WithContext возвращает хранилище, запросы которого выполняются в контексте ctx.
+func (s *Storage) WithContext(ctx context.Context) TaskStore {
+	c := *s
+	c.db = s.db.withContext(ctx)
+	return &c
+}
+
+// InitDB открывает базу SQLite, создавая файл при необходимости, и применяет
+// недостающие миграции. Если схема базы новее, чем знает сервер, возвращается
+// ErrSchemaTooNew.
 func InitDB(dbFileName string) (*sql.DB, error) {
+	db, err := OpenDB(dbFileName)
+	if err != nil {
+		return nil, err
+	}
+	migrator, err := NewSQLiteMigrator(db)
+	if err == nil {
+		_, err = migrator.Up()
+	}
+	if err == nil {
+		err = initSearch(db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Println("Таблица scheduler готова.")
+
+	return db, nil
+}
+
+// OpenDB открывает базу SQLite, создавая файл при необходимости, но не меняет
+// её схему.
+func OpenDB(dbFileName string) (*sql.DB, error) {
 	if _, err := os.Stat(dbFileName); os.IsNotExist(err) {
 		file, err := os.Create(dbFileName)
 		if err != nil {
@@ -43,66 +140,139 @@ func InitDB(dbFileName string) (*sql.DB, error) {
 		log.Println("Создан файл базы данных.")
 	}
 
-	db, err := sql.Open("sqlite3", dbFileName)
+	db, err := sql.Open(sqliteDriver, dbFileName+sqliteParams)
 	if err != nil {
 		return nil, err
 	}
+	configureSQLite(db)
+	return db, nil
+}
 
-	_, err = db.Exec(createTableQuery)
+// scanTask читает задачу из строки, выбранной по taskColumns; extra получают
+// значения дополнительных столбцов, идущих следом.
+func scanTask(row rowScanner, extra ...any) (task.Task, error) {
+	var t task.Task
+	var comment, repeat sql.NullString
+	var tags, fields string
+	dest := []any{&t.ID, &t.Date, &t.Title, &comment, &repeat, &t.Deadline, &t.Estimate, &t.Priority, &t.Version, &tags, &fields}
+	err := row.Scan(append(dest, extra...)...)
 	if err != nil {
-		return nil, err
+		return t, err
 	}
-	log.Println("Таблица scheduler готова.")
+	t.Comment = comment.String
+	t.Repeat = repeat.String
+	if tags != "" {
+		t.Tags = strings.Split(tags, ",")
+	}
+	if fields != "{}" {
+		err = json.Unmarshal([]byte(fields), &t.Fields)
+	}
+	return t, err
+}
 
-	return db, nil
+func saveTags(tx *sqlTx, id any, tags []string) error {
+	if _, err := tx.Exec(`DELETE FROM task_tags WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO task_tags (task_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *Storage) InsertTask(t task.Task) (int64, error) {
-	res, err := s.db.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)`,
-		t.Date, t.Title, t.Comment, t.Repeat)
+// moveTask переносит задачу на новую дату. При выполнении и переносе
+// просроченной задачи крайний срок сдвигается на ту же величину, а массовый
+// перенос его не меняет (см. shiftsDeadline). Если version не 0, задача
+// переносится, только пока её версия равна version, иначе возвращается
+// ErrVersionConflict.
+func (s *Storage) moveTask(tx *sqlTx, t task.Task, newDate string, version int64, action string) error {
+	newDeadline := t.Deadline
+	if shiftsDeadline(action) {
+		var err error
+		if newDeadline, err = date.Shift(t.Deadline, t.Date, newDate); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, newDate, t.ID); err != nil {
+		return err
+	}
+	res, err := tx.Exec(upsertDeadlineQuery, t.ID, newDeadline, version, version)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return res.LastInsertId()
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrVersionConflict
+	}
+
+	after := t
+	after.Date = newDate
+	after.Deadline = newDeadline
+	return s.recordRevision(tx, t.ID, &t, &after, action)
 }
 
-func (s *Storage) GetTasks() ([]task.Task, error) {
-	rows, err := s.db.Query(`SELECT id, date, title, comment, repeat FROM scheduler ORDER BY date ASC LIMIT ?`, TaskLimit)
+func queryTasks(tx *sqlTx, query string, args ...any) ([]task.Task, error) {
+	rows, err := tx.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			log.Println(err)
-		}
-	}()
+	defer rows.Close()
 
-	tasks := make([]task.Task, 0)
+	var tasks []task.Task
 	for rows.Next() {
-		var t task.Task
-		err := rows.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+		t, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
 		tasks = append(tasks, t)
 	}
+	return tasks, rows.Err()
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+func (s *Storage) InsertTask(t task.Task) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
-	return tasks, nil
+	id, err := s.insertTask(tx, t)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-func (s *Storage) GetTask(id string) (task.Task, error) {
-	var t task.Task
-	row := s.db.QueryRow(`SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`, id)
-	err := row.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
-	return t, err
+func (s *Storage) insertTask(tx *sqlTx, t task.Task) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?) RETURNING id`,
+		t.Date, t.Title, t.Comment, t.Repeat).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	t.ID = strconv.FormatInt(id, 10)
+	if _, err := writeTask(tx, t); err != nil {
+		return 0, err
+	}
+	if err := s.recordRevision(tx, t.ID, nil, &t, RevisionCreate); err != nil {
+		return 0, err
+	}
+
+	return id, nil
 }
 
-func (s *Storage) UpdateTask(t task.Task) (int64, error) {
-	res, err := s.db.Exec(`UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`, t.Date, t.Title, t.Comment, t.Repeat, t.ID)
+// writeTask сохраняет все поля существующей задачи. Если у t указана версия,
+// а задача с тех пор изменилась, возвращается ErrVersionConflict.
+func writeTask(tx *sqlTx, t task.Task) (int64, error) {
+	res, err := tx.Exec(`UPDATE scheduler SET date = ?, title = ?, comment = ?, repeat = ? WHERE id = ?`, t.Date, t.Title, t.Comment, t.Repeat, t.ID)
 	if err != nil {
 		return 0, err
 	}
@@ -111,42 +281,276 @@ func (s *Storage) UpdateTask(t task.Task) (int64, error) {
 	if err != nil {
 		return 0, err
 	}
+	if rowsAffected == 0 {
+		return 0, nil
+	}
+
+	res, err = tx.Exec(upsertMetaQuery, t.ID, t.Deadline, t.Estimate, t.Priority, t.Version, t.Version)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if updated == 0 {
+		return 0, ErrVersionConflict
+	}
+	if err := indexTask(tx, t); err != nil {
+		return 0, err
+	}
+	if err := saveTags(tx, t.ID, t.Tags); err != nil {
+		return 0, err
+	}
+	if err := saveFields(tx, t.ID, t.Fields); err != nil {
+		return 0, err
+	}
 
 	return rowsAffected, nil
 }
 
-func (s *Storage) MarkTaskDone(id string) error {
-	var t task.Task
-	row := s.db.QueryRow(`SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?`, id)
-	err := row.Scan(&t.ID, &t.Date, &t.Title, &t.Comment, &t.Repeat)
+// restoreTask записывает задачу с её прежним идентификатором, создавая её заново,
+// если она была удалена. Версия t проверяется так же, как в writeTask.
+func restoreTask(tx *sqlTx, t task.Task) error {
+	_, err := tx.Exec(`INSERT INTO scheduler (id, date, title, comment, repeat) VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		t.ID, t.Date, t.Title, t.Comment, t.Repeat)
 	if err != nil {
 		return err
 	}
+	_, err = writeTask(tx, t)
+	return err
+}
 
-	if t.Repeat == "" {
-		_, err := s.db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+func getTask(tx *sqlTx, id string) (task.Task, error) {
+	return scanTask(tx.QueryRow(selectTaskQuery+` WHERE s.id = ?`, id))
+}
+
+// GetTasks возвращает первые TaskLimit задач, отобранных фильтром f.
+func (s *Storage) GetTasks(f TaskFilter) ([]task.Task, error) {
+	page, err := s.GetTaskPage(f, Page{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Tasks, nil
+}
+
+func (s *Storage) GetTask(id string) (task.Task, error) {
+	t, err := scanTask(s.db.QueryRow(selectTaskQuery+` WHERE s.id = ?`, id))
+	if err != nil {
+		return t, err
+	}
+	t.SetOverdue(time.Now())
+	return t, nil
+}
+
+func (s *Storage) UpdateTask(t task.Task) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := getTask(tx, t.ID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := writeTask(tx, t)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.recordRevision(tx, t.ID, &before, &t, RevisionUpdate); err != nil {
+		return 0, err
+	}
+
+	return rowsAffected, tx.Commit()
+}
+
+func (s *Storage) MarkTaskDone(id string, version int64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
+
+	t, err := getTask(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if t.Repeat == "" {
+		if _, err := s.deleteTask(tx, t, version); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
 
 	newDate, err := date.NextDate(time.Now(), t.Date, t.Repeat)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, newDate, id)
-	return err
+	if err := s.moveTask(tx, t, newDate, version, RevisionDone); err != nil {
+		return err
+	}
+	if _, err := stopTimer(tx, id, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *Storage) DeleteTask(id string) (int64, error) {
-	res, err := s.db.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+func (s *Storage) DeleteTask(id string, version int64) (int64, error) {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := res.RowsAffected()
+	before, err := getTask(tx, id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
 	if err != nil {
 		return 0, err
 	}
 
+	rowsAffected, err := s.deleteTask(tx, before, version)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected, tx.Commit()
+}
+
+// deleteTask удаляет задачу before, прочитанную в той же транзакции, и
+// записывает удаление в историю. Если version не 0, задача удаляется, только
+// пока её версия равна version, иначе возвращается ErrVersionConflict.
+func (s *Storage) deleteTask(tx *sqlTx, before task.Task, version int64) (int64, error) {
+	rowsAffected, err := removeTask(tx, before.ID, version)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.recordRevision(tx, before.ID, &before, nil, RevisionDelete); err != nil {
+		return 0, err
+	}
 	return rowsAffected, nil
 }
+
+// removeTask удаляет строку задачи и останавливает её таймер; остальные
+// данные задачи остаются до PurgeDeleted. Если version не 0, задача
+// удаляется, только пока её версия равна version, иначе возвращается
+// ErrVersionConflict.
+func removeTask(tx *sqlTx, id string, version int64) (int64, error) {
+	res, err := tx.Exec(bumpVersionQuery, id, version, version)
+	if err != nil {
+		return 0, err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if version != 0 && updated == 0 {
+		return 0, ErrVersionConflict
+	}
+
+	res, err = tx.Exec(`DELETE FROM scheduler WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	if err := unindexTask(tx, id); err != nil {
+		return 0, err
+	}
+	if _, err := stopTimer(tx, id, time.Now()); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// PurgeDeleted удаляет заметки, вложения и значения полей задач, которые
+// удалены и не менялись с момента before. Задачу, возвращённую после этого
+// из истории изменений, восстанавливают без них. Возвращается число задач,
+// данные которых удалены.
+func (s *Storage) PurgeDeleted(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT DISTINCT d.task_id FROM (
+	SELECT task_id FROM attachments UNION SELECT task_id FROM task_notes UNION SELECT task_id FROM task_fields
+) d
+WHERE d.task_id NOT IN (SELECT id FROM scheduler)
+	AND NOT EXISTS (SELECT 1 FROM task_revisions r WHERE r.task_id = d.task_id AND r.created_at >= ?)`,
+		before.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var files []string
+	for _, id := range ids {
+		taskFiles, err := attachmentFiles(tx, id)
+		if err != nil {
+			return 0, err
+		}
+		files = append(files, taskFiles...)
+		for _, table := range purgedTables {
+			if _, err := tx.Exec(`DELETE FROM `+table+` WHERE task_id = ?`, id); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	removeFiles(files)
+	return len(ids), nil
+}
+
+// RolloverOverdue переносит просроченные повторяющиеся задачи на следующую
+// дату по их правилу повторения.
+func (s *Storage) RolloverOverdue(now time.Time) ([]DateChange, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tasks, err := queryTasks(tx, selectTaskQuery+` WHERE s.date < ? AND s.repeat != ''`, now.Format(date.DATE_FORMAT))
+	if err != nil {
+		return nil, err
+	}
+
+	rollover := s.as(RolloverAuthor)
+	moved := make([]DateChange, 0, len(tasks))
+	for _, t := range tasks {
+		newDate, err := date.NextDate(now, t.Date, t.Repeat)
+		if err != nil {
+			log.Printf("Не удалось перенести задачу %s: %v", t.ID, err)
+			continue
+		}
+		if err := rollover.moveTask(tx, t, newDate, 0, RevisionRollover); err != nil {
+			return nil, err
+		}
+		moved = append(moved, DateChange{ID: t.ID, Title: t.Title, OldDate: t.Date, NewDate: newDate})
+	}
+
+	return moved, tx.Commit()
+}
@@ -0,0 +1,321 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dialect переводит запросы Storage в SQL конкретной базы. Запросы пишутся
+// для SQLite с параметрами "?"; для остальных баз rebind заменяет параметры
+// и функции SQLite на их аналоги.
+type dialect struct {
+	name   string
+	rebind func(query string) string
+	// busy сообщает, что база временно занята и операцию можно повторить.
+	busy func(err error) bool
+}
+
+var (
+	sqliteDialect   = &dialect{name: "sqlite", rebind: func(query string) string { return query }, busy: isSQLiteBusy}
+	postgresDialect = &dialect{name: "postgres", rebind: cachedRebind(postgresQuery)}
+)
+
+// QueryTimeout ограничивает время одного запроса к базе данных; 0 — без
+// ограничения. Транзакция отменяется целиком, если отменён контекст
+// хранилища (см. TaskStore.WithContext).
+var QueryTimeout = 10 * time.Second
+
+// sqlDB и sqlTx выполняют запросы, переведённые в диалект базы, в контексте
+// ctx с таймаутом QueryTimeout на каждый запрос. Если задан stmts, запросы
+// готовятся один раз и берутся из него.
+type sqlDB struct {
+	*sql.DB
+	dialect *dialect
+	ctx     context.Context
+	stmts   *stmtCache
+}
+
+type sqlTx struct {
+	*sql.Tx
+	dialect *dialect
+	ctx     context.Context
+	stmts   *stmtCache
+}
+
+// sqlRows и sqlRow снимают таймаут запроса, когда строки прочитаны.
+type sqlRows struct {
+	*sql.Rows
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type sqlRow struct {
+	row    *sql.Row
+	err    error
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// sqlConn — общее у *sql.DB и *sql.Tx.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// statement — запрос, готовый к выполнению: подготовленный, если stmt задан,
+// или текст text, который выполняется в conn.
+type statement struct {
+	stmt *sql.Stmt
+	conn sqlConn
+	text string
+}
+
+func (s statement) exec(ctx context.Context, args []any) (sql.Result, error) {
+	if s.stmt != nil {
+		return s.stmt.ExecContext(ctx, args...)
+	}
+	return s.conn.ExecContext(ctx, s.text, args...)
+}
+
+func (s statement) query(ctx context.Context, args []any) (*sqlRows, error) {
+	ctx, cancel := queryContext(ctx)
+	var rows *sql.Rows
+	var err error
+	if s.stmt != nil {
+		rows, err = s.stmt.QueryContext(ctx, args...)
+	} else {
+		rows, err = s.conn.QueryContext(ctx, s.text, args...)
+	}
+	if err != nil {
+		cancel()
+		return nil, contextError(ctx, err)
+	}
+	return &sqlRows{Rows: rows, ctx: ctx, cancel: cancel}, nil
+}
+
+func (s statement) queryRow(ctx context.Context, args []any) *sqlRow {
+	ctx, cancel := queryContext(ctx)
+	if s.stmt != nil {
+		return &sqlRow{row: s.stmt.QueryRowContext(ctx, args...), ctx: ctx, cancel: cancel}
+	}
+	return &sqlRow{row: s.conn.QueryRowContext(ctx, s.text, args...), ctx: ctx, cancel: cancel}
+}
+
+func (db *sqlDB) withContext(ctx context.Context) *sqlDB {
+	c := *db
+	c.ctx = ctx
+	return &c
+}
+
+func (db *sqlDB) context() context.Context {
+	if db.ctx == nil {
+		return context.Background()
+	}
+	return db.ctx
+}
+
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if QueryTimeout > 0 {
+		return context.WithTimeout(ctx, QueryTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// contextError добавляет к ошибке запроса причину отмены: драйвер прерванного
+// запроса не всегда возвращает ошибку контекста.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctx.Err(), err)
+}
+
+func (db *sqlDB) statement(ctx context.Context, query string) (statement, error) {
+	query = db.dialect.rebind(query)
+	stmt, err := db.stmts.get(ctx, query)
+	return statement{stmt: stmt, conn: db.DB, text: query}, contextError(ctx, err)
+}
+
+// Exec повторяет запрос, если база занята: вне транзакции он выполняется
+// целиком или не выполняется вовсе.
+func (db *sqlDB) Exec(query string, args ...any) (res sql.Result, err error) {
+	ctx := db.context()
+	err = retryBusy(ctx, db.dialect, func() error {
+		qctx, cancel := queryContext(ctx)
+		defer cancel()
+		s, err := db.statement(qctx, query)
+		if err == nil {
+			res, err = s.exec(qctx, args)
+		}
+		return contextError(qctx, err)
+	})
+	return res, err
+}
+
+func (db *sqlDB) Query(query string, args ...any) (*sqlRows, error) {
+	s, err := db.statement(db.context(), query)
+	if err != nil {
+		return nil, err
+	}
+	return s.query(db.context(), args)
+}
+
+func (db *sqlDB) QueryRow(query string, args ...any) *sqlRow {
+	s, err := db.statement(db.context(), query)
+	if err != nil {
+		return &sqlRow{err: err, cancel: func() {}}
+	}
+	return s.queryRow(db.context(), args)
+}
+
+// Begin начинает транзакцию, которая откатывается, если контекст хранилища
+// отменён до её фиксации. Если база занята, начало транзакции повторяется.
+func (db *sqlDB) Begin() (*sqlTx, error) {
+	ctx := db.context()
+	var tx *sql.Tx
+	err := retryBusy(ctx, db.dialect, func() (err error) {
+		tx, err = db.DB.BeginTx(ctx, nil)
+		return contextError(ctx, err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sqlTx{Tx: tx, dialect: db.dialect, ctx: ctx, stmts: db.stmts}, nil
+}
+
+// statement привязывает подготовленный запрос из кэша к транзакции.
+func (tx *sqlTx) statement(query string) (statement, error) {
+	query = tx.dialect.rebind(query)
+	stmt, err := tx.stmts.get(tx.ctx, query)
+	if stmt != nil {
+		stmt = tx.Tx.StmtContext(tx.ctx, stmt)
+	}
+	return statement{stmt: stmt, conn: tx.Tx, text: query}, contextError(tx.ctx, err)
+}
+
+func (tx *sqlTx) Exec(query string, args ...any) (sql.Result, error) {
+	s, err := tx.statement(query)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := queryContext(tx.ctx)
+	defer cancel()
+	res, err := s.exec(ctx, args)
+	return res, contextError(ctx, err)
+}
+
+func (tx *sqlTx) Query(query string, args ...any) (*sqlRows, error) {
+	s, err := tx.statement(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.query(tx.ctx, args)
+}
+
+func (tx *sqlTx) QueryRow(query string, args ...any) *sqlRow {
+	s, err := tx.statement(query)
+	if err != nil {
+		return &sqlRow{err: err, cancel: func() {}}
+	}
+	return s.queryRow(tx.ctx, args)
+}
+
+func (tx *sqlTx) Commit() error {
+	return contextError(tx.ctx, tx.Tx.Commit())
+}
+
+func (r *sqlRows) Err() error {
+	return contextError(r.ctx, r.Rows.Err())
+}
+
+func (r *sqlRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+func (r *sqlRow) Scan(dest ...any) error {
+	defer r.cancel()
+	if r.err != nil {
+		return r.err
+	}
+	return contextError(r.ctx, r.row.Scan(dest...))
+}
+
+// cachedRebind запоминает переведённые запросы: текст запросов Storage
+// повторяется, а меняются только параметры.
+func cachedRebind(rebind func(string) string) func(string) string {
+	var cache sync.Map
+	return func(query string) string {
+		if q, ok := cache.Load(query); ok {
+			return q.(string)
+		}
+		q := rebind(query)
+		cache.Store(query, q)
+		return q
+	}
+}
+
+var postgresFunctions = strings.NewReplacer(
+	`GROUP_CONCAT(tt.tag, ',')`, `string_agg(tt.tag, ',' ORDER BY tt.tag)`,
+	`json_group_object(tf.name, json(tf.value))`, `json_object_agg(tf.name, tf.value::json)`,
+	`fold(`, `lower(`,
+)
+
+// postgresQuery переводит запрос в диалект PostgreSQL:
+//   - параметры "?" становятся $1, $2, ...;
+//   - json_extract(x, '$') становится (x)::jsonb, сравнение JSON-значений;
+//   - fold(x) становится lower(x): регистр в условиях LIKE снимается явно, а
+//     не через ILIKE, чтобы текст сравнивался так же, как в SQLite;
+//   - агрегаты меток и пользовательских полей заменяются на string_agg и
+//     json_object_agg.
+func postgresQuery(query string) string {
+	query = postgresFunctions.Replace(query)
+	query = replaceJSONExtract(query)
+
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// replaceJSONExtract заменяет вызовы json_extract(x, '$') с произвольно
+// вложенным x.
+func replaceJSONExtract(query string) string {
+	const call, path = `json_extract(`, `, '$'`
+	for {
+		start := strings.Index(query, call)
+		if start < 0 {
+			return query
+		}
+		depth := 1
+		end := start + len(call)
+		for ; end < len(query) && depth > 0; end++ {
+			switch query[end] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+		}
+		arg := strings.TrimSuffix(query[start+len(call):end-1], path)
+		query = query[:start] + `(` + arg + `)::jsonb` + query[end:]
+	}
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"encoding/json"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Значения пользовательских полей хранятся в task_fields как JSON-литералы,
+// чтобы при чтении и сортировке сохранялся их тип.
+
+func (s *Storage) GetFieldDefs() ([]task.FieldDef, error) {
+	rows, err := s.db.Query(`SELECT name, type, options, tag FROM custom_fields ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defs := make([]task.FieldDef, 0)
+	for rows.Next() {
+		var d task.FieldDef
+		var options string
+		if err := rows.Scan(&d.Name, &d.Type, &options, &d.Tag); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(options), &d.Options); err != nil {
+			return nil, err
+		}
+		defs = append(defs, d)
+	}
+	return defs, rows.Err()
+}
+
+func (s *Storage) InsertFieldDef(d task.FieldDef) error {
+	options, err := json.Marshal(d.Options)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO custom_fields (name, type, options, tag) VALUES (?, ?, ?, ?)`,
+		d.Name, d.Type, string(options), d.Tag)
+	return err
+}
+
+// UpdateFieldDef меняет значения перечисления и метку проекта. Тип поля не меняется.
+func (s *Storage) UpdateFieldDef(d task.FieldDef) (int64, error) {
+	options, err := json.Marshal(d.Options)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.Exec(`UPDATE custom_fields SET options = ?, tag = ? WHERE name = ?`, string(options), d.Tag, d.Name)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteFieldDef удаляет поле вместе с его значениями у всех задач.
+func (s *Storage) DeleteFieldDef(name string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM custom_fields WHERE name = ?`, name)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	// Задачи, у которых было значение поля, меняются, поэтому их версии растут.
+	_, err = tx.Exec(`UPDATE task_meta SET version = version + 1 WHERE task_id IN (SELECT task_id FROM task_fields WHERE name = ?)`, name)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(`DELETE FROM task_fields WHERE name = ?`, name); err != nil {
+		return 0, err
+	}
+	return rowsAffected, tx.Commit()
+}
+
+func saveFields(tx *sqlTx, id any, fields map[string]any) error {
+	if _, err := tx.Exec(`DELETE FROM task_fields WHERE task_id = ?`, id); err != nil {
+		return err
+	}
+	for name, value := range fields {
+		if _, err := tx.Exec(`INSERT INTO task_fields (task_id, name, value) VALUES (?, ?, ?)`, id, name, fieldValue(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fieldValue(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
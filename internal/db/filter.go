@@ -0,0 +1,205 @@
+package db
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// TaskFilter задаёт условия отбора задач. Пустой фильтр подходит под все задачи.
+type TaskFilter struct {
+	DueBefore string   `json:"due_before,omitempty"`
+	Overdue   bool     `json:"overdue,omitempty"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	Tag       string   `json:"tag,omitempty"`
+	IDs       []string `json:"ids,omitempty"`
+
+	// Fields отбирает задачи по значениям пользовательских полей, уже приведённым
+	// к каноническому виду через FieldDef.Normalize.
+	Fields map[string]any `json:"fields,omitempty"`
+
+	// Query — дополнительное условие на языке запросов (см. Query).
+	Query *Query `json:"q,omitempty"`
+
+	// Sort задаёт порядок: одно из SortOrders или "field.<имя>" для сортировки
+	// по пользовательскому полю, ведущий минус — по убыванию. По умолчанию
+	// задачи упорядочены по дате.
+	Sort string `json:"sort,omitempty"`
+}
+
+// IsEmpty сообщает, что в фильтре не задано ни одного условия.
+func (f TaskFilter) IsEmpty() bool {
+	return f.DueBefore == "" && !f.Overdue && f.From == "" && f.To == "" && f.Tag == "" && len(f.IDs) == 0 &&
+		len(f.Fields) == 0 && (f.Query == nil || f.Query.IsEmpty())
+}
+
+func (f TaskFilter) where(now time.Time) (string, []any) {
+	conds, args := f.conditions(now)
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return ` WHERE ` + strings.Join(conds, ` AND `), args
+}
+
+func (f TaskFilter) conditions(now time.Time) ([]string, []any) {
+	var conds []string
+	var args []any
+
+	if f.DueBefore != "" {
+		conds = append(conds, `m.deadline != '' AND m.deadline < ?`)
+		args = append(args, f.DueBefore)
+	}
+	if f.Overdue {
+		today := now.Format(date.DATE_FORMAT)
+		conds = append(conds, `(s.date < ? OR (m.deadline != '' AND m.deadline < ?))`)
+		args = append(args, today, today)
+	}
+	if f.From != "" {
+		conds = append(conds, `s.date >= ?`)
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		conds = append(conds, `s.date <= ?`)
+		args = append(args, f.To)
+	}
+	if f.Tag != "" {
+		conds = append(conds, `EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = s.id AND tt.tag = ?)`)
+		args = append(args, f.Tag)
+	}
+	if len(f.IDs) > 0 {
+		conds = append(conds, `s.id IN (?`+strings.Repeat(`, ?`, len(f.IDs)-1)+`)`)
+		for _, id := range f.IDs {
+			args = append(args, id)
+		}
+	}
+
+	for name, value := range f.Fields {
+		conds = append(conds, `EXISTS (SELECT 1 FROM task_fields tf WHERE tf.task_id = s.id AND tf.name = ? AND tf.value = ?)`)
+		args = append(args, name, fieldValue(value))
+	}
+
+	if f.Query != nil {
+		for _, c := range f.Query.conds {
+			conds = append(conds, c.sql)
+			args = append(args, c.args...)
+		}
+	}
+	return conds, args
+}
+
+// taskRow — задача вместе со сведениями, которые нужны для отбора и сортировки
+// задач без SQL. Created и Updated — номера первой и последней ревизии задачи.
+type taskRow struct {
+	task.Task
+	hasAttachments bool
+	hasNotes       bool
+	created        int64
+	updated        int64
+}
+
+// match проверяет задачу теми же условиями, что и conditions.
+func (f TaskFilter) match(r *taskRow, now time.Time) bool {
+	if f.DueBefore != "" && (r.Deadline == "" || r.Deadline >= f.DueBefore) {
+		return false
+	}
+	if f.Overdue {
+		today := now.Format(date.DATE_FORMAT)
+		if r.Date >= today && (r.Deadline == "" || r.Deadline >= today) {
+			return false
+		}
+	}
+	if (f.From != "" && r.Date < f.From) || (f.To != "" && r.Date > f.To) {
+		return false
+	}
+	if f.Tag != "" && !slices.Contains(r.Tags, f.Tag) {
+		return false
+	}
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, r.ID) {
+		return false
+	}
+	for name, value := range f.Fields {
+		v, ok := r.Fields[name]
+		if !ok || fieldValue(v) != fieldValue(value) {
+			return false
+		}
+	}
+	return f.Query == nil || f.Query.match(r)
+}
+
+// SortOrders перечисляет поддерживаемые значения Sort, кроме сортировки по
+// пользовательскому полю.
+var SortOrders = []string{"date", "title", "priority", "created", "updated"}
+
+// orderKeys возвращает выражения ключа сортировки и их параметры. Задачи
+// упорядочены по всем выражениям в одном направлении, последнее — s.id, поэтому
+// ключ однозначно задаёт место задачи в списке.
+func (f TaskFilter) orderKeys() ([]string, []any, bool) {
+	sort, desc := strings.CutPrefix(f.Sort, "-")
+
+	if name, ok := strings.CutPrefix(sort, "field."); ok {
+		// Задачи без значения поля идут первыми по возрастанию и последними по
+		// убыванию, как NULL в SQLite.
+		value := `(SELECT tf.value FROM task_fields tf WHERE tf.task_id = s.id AND tf.name = ?)`
+		return []string{value + ` IS NOT NULL`, `json_extract(COALESCE(` + value + `, '0'), '$')`, `s.date`, `s.id`}, []any{name, name}, desc
+	}
+
+	switch sort {
+	case "title":
+		return []string{`s.title`, `s.id`}, nil, desc
+	case "priority":
+		return []string{`COALESCE(m.priority, 0)`, `s.date`, `s.id`}, nil, desc
+	case "created", "updated":
+		// Время создания и изменения задачи определяется по её первой и последней
+		// ревизии; номера ревизий растут вместе со временем.
+		revision := `MIN`
+		if sort == "updated" {
+			revision = `MAX`
+		}
+		return []string{`COALESCE((SELECT ` + revision + `(r.id) FROM task_revisions r WHERE r.task_id = s.id), 0)`, `s.id`}, nil, desc
+	case "date":
+		return []string{`s.date`, `s.id`}, nil, desc
+	}
+	return []string{`s.date`, `s.id`}, nil, false
+}
+
+// sortKey возвращает значения ключа сортировки задачи — те же, что SQLite
+// вычисляет по выражениям orderKeys.
+func (f TaskFilter) sortKey(r *taskRow) []any {
+	id, _ := strconv.ParseInt(r.ID, 10, 64)
+	sort, _ := strings.CutPrefix(f.Sort, "-")
+
+	if name, ok := strings.CutPrefix(sort, "field."); ok {
+		value := sqlValue(r.Fields[name])
+		if value == nil {
+			return []any{int64(0), int64(0), r.Date, id}
+		}
+		return []any{int64(1), value, r.Date, id}
+	}
+
+	switch sort {
+	case "title":
+		return []any{r.Title, id}
+	case "priority":
+		return []any{int64(r.Priority), r.Date, id}
+	case "created":
+		return []any{r.created, id}
+	case "updated":
+		return []any{r.updated, id}
+	}
+	return []any{r.Date, id}
+}
+
+// compareKeys сравнивает ключи сортировки покомпонентно.
+func compareKeys(a, b []any) int {
+	for i := range min(len(a), len(b)) {
+		if c, _ := compareValues(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,397 @@
+package db
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// JournalExt — расширение TODO_DBFILE, при котором данные хранятся в журнале
+// (см. OpenJournal), а не в SQLite.
+const JournalExt = ".jsonl"
+
+// journalCompactEvery — число записей, после которого журнал сжимается.
+const journalCompactEvery = 1000
+
+// Журнал — файл JSON Lines. Каждая строка — одна запись: изменения одной
+// операции хранилища, которые применяются целиком или не применяются вовсе.
+//
+//	{"ops":[{"t":"tasks","k":1,"v":{...},"s":1},{"t":"task_revisions","k":1,"v":{...},"s":1}]}
+type journalRecord struct {
+	Ops []journalOp `json:"ops"`
+}
+
+// journalOp — новое значение записи таблицы или её удаление, если Value пусто.
+// Seq — последний выданный идентификатор таблицы. Операция без ключа только
+// восстанавливает Seq.
+type journalOp struct {
+	Table string          `json:"t"`
+	Key   json.RawMessage `json:"k,omitempty"`
+	Value json.RawMessage `json:"v,omitempty"`
+	Seq   int64           `json:"s,omitempty"`
+}
+
+// journalTable — таблица хранилища, которая пишется в журнал и читается из него.
+type journalTable interface {
+	tableName() string
+	apply(op journalOp) error
+	snapshot() ([]journalOp, error)
+}
+
+func (d *memoryData) journalTables() []journalTable {
+	return []journalTable{
+		&d.tasks, &d.timeEntries, &d.pomodoros, &d.attachments, &d.notes,
+		&d.fieldDefs, &d.revisions, &d.templates, &d.views, &d.deletedVersions,
+	}
+}
+
+// Поля, скрытые от JSON в ответах API, в журнале сохраняются.
+type journalTask struct {
+	task.Task
+	Version int64 `json:"version"`
+}
+
+type journalRevision struct {
+	Revision
+	Task task.Task `json:"task"`
+}
+
+type journalPomodoro struct {
+	PomodoroSession
+	EndedAt   time.Time `json:"ended_at"`
+	Completed bool      `json:"completed"`
+}
+
+type journalAttachment struct {
+	memoryAttachment
+	Path string `json:"path"`
+}
+
+func setJournalCodecs(d *memoryData) {
+	d.tasks.encode = func(t task.Task) any { return journalTask{t, t.Version} }
+	d.tasks.decode = func(data []byte) (task.Task, error) {
+		var j journalTask
+		err := json.Unmarshal(data, &j)
+		j.Task.Version = j.Version
+		return j.Task, err
+	}
+	d.revisions.encode = func(r Revision) any { return journalRevision{r, r.Task} }
+	d.revisions.decode = func(data []byte) (Revision, error) {
+		var j journalRevision
+		err := json.Unmarshal(data, &j)
+		j.Revision.Task = j.Task
+		return j.Revision, err
+	}
+	d.pomodoros.encode = func(p PomodoroSession) any { return journalPomodoro{p, p.EndedAt, p.Completed} }
+	d.pomodoros.decode = func(data []byte) (PomodoroSession, error) {
+		var j journalPomodoro
+		err := json.Unmarshal(data, &j)
+		j.PomodoroSession.EndedAt, j.PomodoroSession.Completed = j.EndedAt, j.Completed
+		return j.PomodoroSession, err
+	}
+	d.attachments.encode = func(a memoryAttachment) any { return journalAttachment{a, a.Path} }
+	d.attachments.decode = func(data []byte) (memoryAttachment, error) {
+		var j journalAttachment
+		err := json.Unmarshal(data, &j)
+		j.memoryAttachment.Path = j.Path
+		return j.memoryAttachment, err
+	}
+}
+
+func (t *memoryTable[K, V]) tableName() string {
+	return t.name
+}
+
+// op возвращает запись журнала с текущим значением записи key.
+func (t *memoryTable[K, V]) op(key K) (journalOp, error) {
+	op := journalOp{Table: t.name, Seq: t.seq}
+	var err error
+	if op.Key, err = json.Marshal(key); err != nil {
+		return op, err
+	}
+	if v, ok := t.rows[key]; ok {
+		var value any = v
+		if t.encode != nil {
+			value = t.encode(v)
+		}
+		if op.Value, err = json.Marshal(value); err != nil {
+			return op, err
+		}
+	}
+	return op, nil
+}
+
+func (t *memoryTable[K, V]) apply(op journalOp) error {
+	t.seq = max(t.seq, op.Seq)
+	if len(op.Key) == 0 {
+		return nil
+	}
+	var key K
+	if err := json.Unmarshal(op.Key, &key); err != nil {
+		return err
+	}
+	if len(op.Value) == 0 {
+		t.unset(key)
+		return nil
+	}
+
+	var value V
+	var err error
+	if t.decode != nil {
+		value, err = t.decode(op.Value)
+	} else {
+		err = json.Unmarshal(op.Value, &value)
+	}
+	if err != nil {
+		return err
+	}
+	t.set(key, value)
+	return nil
+}
+
+// snapshot возвращает записи журнала, из которых таблица восстанавливается
+// целиком.
+func (t *memoryTable[K, V]) snapshot() ([]journalOp, error) {
+	keys := make([]K, 0, len(t.rows))
+	for k := range t.rows {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	ops := []journalOp{{Table: t.name, Seq: t.seq}}
+	for _, k := range keys {
+		op, err := t.op(k)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// logChange запоминает, что запись key изменена в транзакции. Значение для
+// журнала берётся при фиксации транзакции, когда все её изменения сделаны.
+func logChange[K cmp.Ordered, V any](tx *memoryTx, t *memoryTable[K, V], key K) {
+	if tx.logged {
+		tx.journal = append(tx.journal, func() (journalOp, error) { return t.op(key) })
+	}
+}
+
+type journal struct {
+	path string
+	file *os.File
+	// size — длина журнала после последней записи, сохранённой на диске.
+	size int64
+	// records — число записей, дописанных после последнего сжатия.
+	records int
+}
+
+// OpenJournal открывает хранилище, которое держит данные в памяти, как
+// MemoryStorage, а каждое изменение дописывает в журнал path и сбрасывает
+// его на диск до того, как вернуть результат. Для него не нужен cgo.
+//
+// При открытии журнал читается целиком и сразу сжимается; дальше он
+// сжимается каждые journalCompactEvery записей. Недописанная при сбое
+// последняя строка отбрасывается.
+func OpenJournal(path string) (*MemoryStorage, error) {
+	d := newMemoryData()
+	setJournalCodecs(d)
+	if err := replayJournal(d, path); err != nil {
+		return nil, err
+	}
+
+	j := &journal{path: path}
+	if err := j.compact(d); err != nil {
+		if j.file != nil {
+			j.file.Close()
+		}
+		return nil, err
+	}
+	d.journal = j
+	return &MemoryStorage{data: d}, nil
+}
+
+// Close закрывает журнал хранилища. Хранилище без журнала закрывать не нужно.
+func (s *MemoryStorage) Close() error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+
+	if s.data.journal == nil {
+		return nil
+	}
+	err := s.data.journal.file.Close()
+	s.data.journal = nil
+	return err
+}
+
+func replayJournal(d *memoryData, path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tables := make(map[string]journalTable)
+	for _, t := range d.journalTables() {
+		tables[t.tableName()] = t
+	}
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				log.Printf("Недописанная последняя запись журнала %s отброшена.", path)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec journalRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if _, err := r.Peek(1); err == io.EOF {
+				log.Printf("Повреждённая последняя запись журнала %s отброшена.", path)
+				return nil
+			}
+			return fmt.Errorf("журнал %s повреждён в строке %d: %w", path, n, err)
+		}
+		for _, op := range rec.Ops {
+			t, ok := tables[op.Table]
+			if !ok {
+				return fmt.Errorf("журнал %s, строка %d: неизвестная таблица %q", path, n, op.Table)
+			}
+			if err := t.apply(op); err != nil {
+				return fmt.Errorf("журнал %s, строка %d: %w", path, n, err)
+			}
+		}
+	}
+}
+
+// commit дописывает изменения транзакции в журнал одной записью.
+func (j *journal) commit(d *memoryData, tx *memoryTx) error {
+	rec := journalRecord{Ops: make([]journalOp, 0, len(tx.journal))}
+	seen := make(map[string]bool)
+	for _, change := range tx.journal {
+		op, err := change()
+		if err != nil {
+			return err
+		}
+		if id := op.Table + ":" + string(op.Key); !seen[id] {
+			seen[id] = true
+			rec.Ops = append(rec.Ops, op)
+		}
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := j.append(append(line, '\n')); err != nil {
+		return err
+	}
+
+	j.records++
+	if j.records >= journalCompactEvery {
+		// Запись уже сохранена, поэтому ошибка сжатия её не отменяет.
+		if err := j.compact(d); err != nil {
+			log.Printf("Ошибка сжатия журнала %s: %v", j.path, err)
+		}
+	}
+	return nil
+}
+
+// append дописывает строку и ждёт, пока она окажется на диске. Если записать
+// не удалось, недописанный конец обрезается, чтобы следующая запись не
+// склеилась с ним.
+func (j *journal) append(line []byte) error {
+	_, err := j.file.Write(line)
+	if err == nil {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		j.file.Truncate(j.size)
+		return err
+	}
+	j.size += int64(len(line))
+	return nil
+}
+
+// compact записывает текущее состояние таблиц в новый файл, сбрасывает его на
+// диск и атомарно подменяет им журнал. При сбое до подмены остаётся прежний
+// журнал.
+func (j *journal) compact(d *memoryData) (err error) {
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil && j.file != f {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	var size int64
+	for _, t := range d.journalTables() {
+		ops, err := t.snapshot()
+		if err != nil {
+			return err
+		}
+		for _, op := range ops {
+			line, err := json.Marshal(journalRecord{Ops: []journalOp{op}})
+			if err != nil {
+				return err
+			}
+			n, err := w.Write(append(line, '\n'))
+			if err != nil {
+				return err
+			}
+			size += int64(n)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+
+	// После переименования журналом стал новый файл, и писать надо в него,
+	// даже если каталог не удалось сбросить на диск: прежний файл уже удалён.
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file, j.size, j.records = f, size, 0
+	return syncDir(filepath.Dir(j.path))
+}
+
+// syncDir сбрасывает на диск каталог, чтобы переименование файла в нём
+// пережило сбой.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
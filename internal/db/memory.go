@@ -0,0 +1,915 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+	"github.com/imbalaancing/go_final_project/internal/templates"
+)
+
+// MemoryDSN — значение TODO_DBFILE, при котором данные хранятся в памяти
+// процесса и теряются при его остановке.
+const MemoryDSN = ":memory:"
+
+// MemoryStorage — хранилище в памяти процесса для демонстраций и тестов.
+// Оно ведёт себя так же, как Storage: те же фильтры, сортировки, история
+// изменений и ошибки.
+type MemoryStorage struct {
+	data           *memoryData
+	attachmentsDir string
+	author         string
+	undo           *[]UndoStep
+}
+
+// memoryData — таблицы хранилища. Задача хранится целиком, вместе с метками,
+// оценкой и пользовательскими полями.
+type memoryData struct {
+	mu          sync.RWMutex
+	tasks       memoryTable[int64, task.Task]
+	dates       dateIndex
+	timeEntries memoryTable[int64, timeEntry]
+	pomodoros   memoryTable[int64, PomodoroSession]
+	attachments memoryTable[int64, memoryAttachment]
+	notes       memoryTable[int64, Note]
+	fieldDefs   memoryTable[string, task.FieldDef]
+	revisions   memoryTable[int64, Revision]
+	templates   memoryTable[int64, templates.Template]
+	views       memoryTable[int64, View]
+	// deletedVersions хранит версии удалённых задач: как со строкой task_meta
+	// в Storage, версия восстановленной задачи продолжает расти, и ETag,
+	// выданный до удаления, с ней не совпадёт.
+	deletedVersions memoryTable[int64, int64]
+
+	// journal, если задан, получает все изменения таблиц (см. OpenJournal).
+	journal *journal
+}
+
+// timeEntry — интервал работы над задачей; пока таймер запущен, StoppedAt пуст.
+type timeEntry struct {
+	ID        int64
+	TaskID    string
+	StartedAt time.Time
+	StoppedAt time.Time
+}
+
+type memoryAttachment struct {
+	Attachment
+	Data []byte
+}
+
+type memoryTable[K cmp.Ordered, V any] struct {
+	name string
+	rows map[K]V
+	// seq — последний выданный идентификатор. Как AUTOINCREMENT в SQLite,
+	// идентификаторы удалённых записей не используются повторно.
+	seq int64
+	// changed вызывается при каждом изменении записи; old или new равны nil,
+	// если записи не было или она удалена. Через него обновляются индексы.
+	changed func(key K, old, new *V)
+	// encode и decode переводят запись в журнал и обратно, если у неё есть
+	// поля, скрытые от JSON. По умолчанию запись пишется в журнал как есть.
+	encode func(V) any
+	decode func(data []byte) (V, error)
+}
+
+func newMemoryTable[K cmp.Ordered, V any](name string) memoryTable[K, V] {
+	return memoryTable[K, V]{name: name, rows: make(map[K]V)}
+}
+
+func (t *memoryTable[K, V]) get(key K) (V, bool) {
+	v, ok := t.rows[key]
+	return v, ok
+}
+
+func (t *memoryTable[K, V]) set(key K, value V) {
+	old, existed := t.rows[key]
+	t.rows[key] = value
+	if t.changed != nil {
+		if existed {
+			t.changed(key, &old, &value)
+		} else {
+			t.changed(key, nil, &value)
+		}
+	}
+}
+
+func (t *memoryTable[K, V]) unset(key K) {
+	old, existed := t.rows[key]
+	if !existed {
+		return
+	}
+	delete(t.rows, key)
+	if t.changed != nil {
+		t.changed(key, &old, nil)
+	}
+}
+
+func (t *memoryTable[K, V]) nextID() int64 {
+	t.seq++
+	return t.seq
+}
+
+// sorted возвращает записи в порядке ключей.
+func (t *memoryTable[K, V]) sorted() []V {
+	keys := make([]K, 0, len(t.rows))
+	for k := range t.rows {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	values := make([]V, len(keys))
+	for i, k := range keys {
+		values[i] = t.rows[k]
+	}
+	return values
+}
+
+// memoryTx запоминает прежние значения изменённых записей, чтобы при ошибке
+// вернуть таблицы к состоянию до начала записи, как откат транзакции. Если
+// хранилище ведёт журнал, memoryTx собирает и записи для него.
+type memoryTx struct {
+	rollback []func()
+	journal  []func() (journalOp, error)
+	logged   bool
+}
+
+func put[K cmp.Ordered, V any](tx *memoryTx, t *memoryTable[K, V], key K, value V) {
+	old, existed := t.rows[key]
+	tx.rollback = append(tx.rollback, func() {
+		if existed {
+			t.set(key, old)
+		} else {
+			t.unset(key)
+		}
+	})
+	t.set(key, value)
+	logChange(tx, t, key)
+}
+
+func remove[K cmp.Ordered, V any](tx *memoryTx, t *memoryTable[K, V], key K) bool {
+	old, existed := t.rows[key]
+	if !existed {
+		return false
+	}
+	tx.rollback = append(tx.rollback, func() { t.set(key, old) })
+	t.unset(key)
+	logChange(tx, t, key)
+	return true
+}
+
+func (tx *memoryTx) undo() {
+	for i := len(tx.rollback) - 1; i >= 0; i-- {
+		tx.rollback[i]()
+	}
+}
+
+func newMemoryData() *memoryData {
+	d := &memoryData{
+		tasks:       newMemoryTable[int64, task.Task]("tasks"),
+		timeEntries: newMemoryTable[int64, timeEntry]("time_entries"),
+		pomodoros:   newMemoryTable[int64, PomodoroSession]("pomodoro_sessions"),
+		attachments: newMemoryTable[int64, memoryAttachment]("attachments"),
+		notes:       newMemoryTable[int64, Note]("task_notes"),
+		fieldDefs:   newMemoryTable[string, task.FieldDef]("custom_fields"),
+		revisions:   newMemoryTable[int64, Revision]("task_revisions"),
+		templates:   newMemoryTable[int64, templates.Template]("task_templates"),
+		views:       newMemoryTable[int64, View]("task_views"),
+
+		deletedVersions: newMemoryTable[int64, int64]("deleted_task_versions"),
+	}
+	d.tasks.changed = d.dates.update
+	return d
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{data: newMemoryData()}
+}
+
+func (s *MemoryStorage) As(author string) TaskStore {
+	c := *s
+	c.author = author
+	return &c
+}
+
+func (s *MemoryStorage) Recording(steps *[]UndoStep) TaskStore {
+	c := *s
+	c.undo = steps
+	return &c
+}
+
+// WithContext возвращает то же хранилище: операции в памяти не ждут базу
+// данных, и отменять в них нечего.
+func (s *MemoryStorage) WithContext(ctx context.Context) TaskStore {
+	return s
+}
+
+// write выполняет fn под блокировкой записи. Если fn вернула ошибку, все её
+// изменения отменяются. Если хранилище ведёт журнал, изменения считаются
+// записанными только после того, как они сохранены в журнале.
+func (s *MemoryStorage) write(fn func(tx *memoryTx) error) error {
+	s.data.mu.Lock()
+	defer s.data.mu.Unlock()
+
+	tx := &memoryTx{logged: s.data.journal != nil}
+	if err := fn(tx); err != nil {
+		tx.undo()
+		return err
+	}
+	if tx.logged && len(tx.journal) > 0 {
+		if err := s.data.journal.commit(s.data, tx); err != nil {
+			tx.undo()
+			return err
+		}
+	}
+	return nil
+}
+
+// cloneJSON копирует значение через JSON, как при сохранении в SQLite: числа
+// в произвольных значениях становятся float64, пустые списки — nil.
+func cloneJSON[T any](v T) T {
+	var c T
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil {
+		log.Printf("Ошибка копирования записи: %v", err)
+	}
+	return c
+}
+
+// cloneTask копирует задачу так же, как cloneJSON, сохраняя её версию.
+func cloneTask(t task.Task) task.Task {
+	c := cloneJSON(t)
+	c.Version = t.Version
+	return c
+}
+
+// storedTask приводит задачу к виду, в котором её возвращает Storage: без
+// вычисляемых полей и с метками по алфавиту.
+func storedTask(t task.Task) task.Task {
+	c := cloneJSON(t)
+	c.Overdue = false
+	c.Snippet = ""
+	slices.Sort(c.Tags)
+	return c
+}
+
+func memoryTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
+// memoryKey разбирает идентификатор записи. Неверный идентификатор не
+// совпадает ни с одной записью.
+func memoryKey(id string) int64 {
+	key, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return key
+}
+
+func (d *memoryData) getTask(id string) (task.Task, error) {
+	t, ok := d.tasks.get(memoryKey(id))
+	if !ok {
+		return task.Task{}, sql.ErrNoRows
+	}
+	return cloneTask(t), nil
+}
+
+// taskRows возвращает все задачи по порядку дат вместе со сведениями для
+// фильтров и сортировок.
+func (d *memoryData) taskRows() []*taskRow {
+	attached := make(map[string]bool)
+	for _, a := range d.attachments.rows {
+		attached[a.TaskID] = true
+	}
+	noted := make(map[string]bool)
+	for _, n := range d.notes.rows {
+		noted[n.TaskID] = true
+	}
+	created := make(map[string]int64)
+	updated := make(map[string]int64)
+	for id, r := range d.revisions.rows {
+		if c, ok := created[r.TaskID]; !ok || id < c {
+			created[r.TaskID] = id
+		}
+		updated[r.TaskID] = max(updated[r.TaskID], id)
+	}
+
+	tasks := d.tasksByDate()
+	rows := make([]*taskRow, len(tasks))
+	for i, t := range tasks {
+		rows[i] = &taskRow{
+			Task:           t,
+			hasAttachments: attached[t.ID],
+			hasNotes:       noted[t.ID],
+			created:        created[t.ID],
+			updated:        updated[t.ID],
+		}
+	}
+	return rows
+}
+
+// filterTasks возвращает задачи, отобранные фильтром f, по возрастанию даты.
+func (d *memoryData) filterTasks(f TaskFilter, now time.Time) []task.Task {
+	var tasks []task.Task
+	for _, r := range d.taskRows() {
+		if f.match(r, now) {
+			tasks = append(tasks, r.Task)
+		}
+	}
+	return tasks
+}
+
+// tasksByDate возвращает все задачи по порядку дат.
+func (d *memoryData) tasksByDate() []task.Task {
+	return d.indexedTasks(d.dates.keys)
+}
+
+// tasksBefore возвращает задачи с датой раньше day по порядку дат.
+func (d *memoryData) tasksBefore(day string) []task.Task {
+	return d.indexedTasks(d.dates.keys[:d.dates.search(day, 0)])
+}
+
+func (d *memoryData) indexedTasks(keys []dateKey) []task.Task {
+	tasks := make([]task.Task, len(keys))
+	for i, k := range keys {
+		tasks[i] = d.tasks.rows[k.id]
+	}
+	return tasks
+}
+
+// dateIndex — ключи задач (дата, идентификатор) по возрастанию. Им задачи
+// выбираются в порядке дат без сортировки при каждом запросе.
+type dateIndex struct {
+	keys []dateKey
+}
+
+type dateKey struct {
+	date string
+	id   int64
+}
+
+func compareDateKeys(a, b dateKey) int {
+	return cmp.Or(cmp.Compare(a.date, b.date), cmp.Compare(a.id, b.id))
+}
+
+// search возвращает место ключа (date, id) в индексе.
+func (x *dateIndex) search(date string, id int64) int {
+	i, _ := slices.BinarySearchFunc(x.keys, dateKey{date, id}, compareDateKeys)
+	return i
+}
+
+func (x *dateIndex) update(id int64, old, new *task.Task) {
+	if old != nil {
+		if i, ok := slices.BinarySearchFunc(x.keys, dateKey{old.Date, id}, compareDateKeys); ok {
+			x.keys = slices.Delete(x.keys, i, i+1)
+		}
+	}
+	if new != nil {
+		x.keys = slices.Insert(x.keys, x.search(new.Date, id), dateKey{new.Date, id})
+	}
+}
+
+func (s *MemoryStorage) InsertTask(t task.Task) (int64, error) {
+	var id int64
+	err := s.write(func(tx *memoryTx) error {
+		id = s.insertTask(tx, t)
+		return nil
+	})
+	return id, err
+}
+
+func (s *MemoryStorage) InsertTasks(tasks []task.Task) ([]string, error) {
+	ids := make([]string, 0, len(tasks))
+	err := s.write(func(tx *memoryTx) error {
+		for _, t := range tasks {
+			ids = append(ids, strconv.FormatInt(s.insertTask(tx, t), 10))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func (s *MemoryStorage) insertTask(tx *memoryTx, t task.Task) int64 {
+	id := s.data.tasks.nextID()
+	t.ID = strconv.FormatInt(id, 10)
+	s.putTask(tx, t)
+	s.recordRevision(tx, t.ID, nil, &t, RevisionCreate)
+	return id
+}
+
+// restoreTask записывает задачу с её прежним идентификатором, создавая её
+// заново, если она была удалена.
+func (s *MemoryStorage) restoreTask(tx *memoryTx, t task.Task) {
+	s.putTask(tx, t)
+	s.data.tasks.seq = max(s.data.tasks.seq, memoryKey(t.ID))
+}
+
+func (s *MemoryStorage) putTask(tx *memoryTx, t task.Task) {
+	id := memoryKey(t.ID)
+	stored := storedTask(t)
+	stored.ID = strconv.FormatInt(id, 10)
+	old, ok := s.data.tasks.get(id)
+	if !ok {
+		if version, deleted := s.data.deletedVersions.get(id); deleted {
+			old.Version = version
+			remove(tx, &s.data.deletedVersions, id)
+		}
+	}
+	stored.Version = old.Version + 1
+	put(tx, &s.data.tasks, id, stored)
+}
+
+func (s *MemoryStorage) GetTask(id string) (task.Task, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	t, err := s.data.getTask(id)
+	if err != nil {
+		return t, err
+	}
+	t.SetOverdue(time.Now())
+	return t, nil
+}
+
+func (s *MemoryStorage) GetTasks(f TaskFilter) ([]task.Task, error) {
+	page, err := s.GetTaskPage(f, Page{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Tasks, nil
+}
+
+func (s *MemoryStorage) GetTaskPage(f TaskFilter, p Page) (TaskPage, error) {
+	now := time.Now()
+	exprs, _, desc := f.orderKeys()
+	if p.Cursor != nil && len(p.Cursor.Key) != len(exprs) {
+		return TaskPage{}, ErrInvalidCursor
+	}
+
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	var rows []*taskRow
+	for _, r := range s.data.taskRows() {
+		if f.match(r, now) {
+			rows = append(rows, r)
+		}
+	}
+	total := len(rows)
+
+	// Как и в SQLite, страница назад выбирается в обратном порядке.
+	back := p.Cursor != nil && p.Cursor.Back
+	reverse := desc != back
+	keys := make(map[*taskRow][]any, len(rows))
+	for _, r := range rows {
+		keys[r] = f.sortKey(r)
+	}
+	if len(exprs) == 2 && exprs[0] == `s.date` {
+		// Задачи уже идут по порядку дат из индекса.
+		if reverse {
+			slices.Reverse(rows)
+		}
+	} else {
+		sort.Slice(rows, func(i, j int) bool {
+			c := compareKeys(keys[rows[i]], keys[rows[j]])
+			if reverse {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+
+	limit := p.limit()
+	var tasks []task.Task
+	var pageKeys [][]any
+	for _, r := range rows {
+		key := keys[r]
+		if p.Cursor != nil {
+			c := compareKeys(key, p.Cursor.Key)
+			if (!reverse && c <= 0) || (reverse && c >= 0) {
+				continue
+			}
+		}
+		t := cloneTask(r.Task)
+		t.SetOverdue(now)
+		tasks = append(tasks, t)
+		pageKeys = append(pageKeys, key)
+		if len(tasks) > limit {
+			break
+		}
+	}
+
+	page := keysetPage(tasks, pageKeys, p, limit)
+	if p.Total {
+		page.Total = total
+	}
+	return page, nil
+}
+
+// SearchTasks ищет задачи так же, как Storage без FTS5: по вхождению всех слов
+// text в заголовок или комментарий, по порядку дат.
+func (s *MemoryStorage) SearchTasks(text string, f TaskFilter, p Page) (TaskPage, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return s.GetTaskPage(f, p)
+	}
+
+	now := time.Now()
+	s.data.mu.RLock()
+	var found []task.Task
+	for _, t := range s.data.filterTasks(f, now) {
+		if containsTerms(t, terms) {
+			found = append(found, t)
+		}
+	}
+	s.data.mu.RUnlock()
+
+	offset := 0
+	if p.Cursor != nil {
+		offset = p.Cursor.Offset
+	}
+	limit := p.limit()
+
+	tasks := make([]task.Task, 0)
+	for _, t := range found[min(offset, len(found)):min(offset+limit, len(found))] {
+		t = cloneTask(t)
+		t.Snippet = snippet(t, terms)
+		t.SetOverdue(now)
+		tasks = append(tasks, t)
+	}
+
+	page := offsetPage(tasks, offset, len(found) > offset+limit, limit)
+	if p.Total {
+		page.Total = len(found)
+	}
+	return page, nil
+}
+
+func (s *MemoryStorage) UpdateTask(t task.Task) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		before, err := s.data.getTask(t.ID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if t.Version != 0 && t.Version != before.Version {
+			return ErrVersionConflict
+		}
+		s.putTask(tx, t)
+		s.recordRevision(tx, t.ID, &before, &t, RevisionUpdate)
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+// moveTask переносит задачу на новую дату. При выполнении и переносе
+// просроченной задачи крайний срок сдвигается на ту же величину, а массовый
+// перенос его не меняет (см. shiftsDeadline). Если version не 0, задача
+// переносится, только пока её версия равна version, иначе возвращается
+// ErrVersionConflict.
+func (s *MemoryStorage) moveTask(tx *memoryTx, t task.Task, newDate string, version int64, action string) error {
+	if version != 0 && version != t.Version {
+		return ErrVersionConflict
+	}
+	newDeadline := t.Deadline
+	if shiftsDeadline(action) {
+		var err error
+		if newDeadline, err = date.Shift(t.Deadline, t.Date, newDate); err != nil {
+			return err
+		}
+	}
+
+	after := t
+	after.Date = newDate
+	after.Deadline = newDeadline
+	s.putTask(tx, after)
+	s.recordRevision(tx, t.ID, &t, &after, action)
+	return nil
+}
+
+func (s *MemoryStorage) MarkTaskDone(id string, version int64) error {
+	return s.write(func(tx *memoryTx) error {
+		t, err := s.data.getTask(id)
+		if err != nil {
+			return err
+		}
+
+		if t.Repeat == "" {
+			if version != 0 && version != t.Version {
+				return ErrVersionConflict
+			}
+			s.deleteTask(tx, id)
+			s.recordRevision(tx, id, &t, nil, RevisionDelete)
+			return nil
+		}
+
+		newDate, err := date.NextDate(time.Now(), t.Date, t.Repeat)
+		if err != nil {
+			return err
+		}
+		if err := s.moveTask(tx, t, newDate, version, RevisionDone); err != nil {
+			return err
+		}
+		s.stopTimer(tx, id, time.Now())
+		return nil
+	})
+}
+
+func (s *MemoryStorage) DeleteTask(id string, version int64) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		before, err := s.data.getTask(id)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if version != 0 && version != before.Version {
+			return ErrVersionConflict
+		}
+		s.deleteTask(tx, id)
+		s.recordRevision(tx, id, &before, nil, RevisionDelete)
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+// deleteTask удаляет задачу и останавливает её таймер. Как и в Storage,
+// остальные данные задачи остаются до PurgeDeleted.
+func (s *MemoryStorage) deleteTask(tx *memoryTx, id string) {
+	key := memoryKey(id)
+	if t, ok := s.data.tasks.get(key); ok {
+		put(tx, &s.data.deletedVersions, key, t.Version+1)
+	}
+	remove(tx, &s.data.tasks, key)
+	s.stopTimer(tx, id, time.Now())
+}
+
+// reportTask возвращает задачу для отчётов. Удалённая задача берётся из
+// последней ревизии: как в Storage, её оценка, метки и название остаются в
+// отчётах об учтённом времени и фокус-сессиях. deleted запоминает уже
+// найденные удалённые задачи.
+func (d *memoryData) reportTask(id string, deleted map[string]task.Task) task.Task {
+	if t, ok := d.tasks.get(memoryKey(id)); ok {
+		return t
+	}
+	if t, ok := deleted[id]; ok {
+		return t
+	}
+	var last Revision
+	for _, r := range d.revisions.rows {
+		if r.TaskID == id && r.ID > last.ID {
+			last = r
+		}
+	}
+	deleted[id] = last.Task
+	return last.Task
+}
+
+// hasTask сообщает, что задача id существует и не удалена.
+func (d *memoryData) hasTask(id string) bool {
+	_, ok := d.tasks.get(memoryKey(id))
+	return ok
+}
+
+func (s *MemoryStorage) PurgeDeleted(before time.Time) (int, error) {
+	var files []string
+	purged := make(map[string]bool)
+	err := s.write(func(tx *memoryTx) error {
+		changed := make(map[string]time.Time)
+		for _, r := range s.data.revisions.rows {
+			if r.CreatedAt.After(changed[r.TaskID]) {
+				changed[r.TaskID] = r.CreatedAt
+			}
+		}
+		stale := func(taskID string) bool {
+			return !s.data.hasTask(taskID) && changed[taskID].Before(before)
+		}
+
+		for key, a := range s.data.attachments.rows {
+			if stale(a.TaskID) {
+				if a.Path != "" {
+					files = append(files, a.Path)
+				}
+				remove(tx, &s.data.attachments, key)
+				purged[a.TaskID] = true
+			}
+		}
+		for key, n := range s.data.notes.rows {
+			if stale(n.TaskID) {
+				remove(tx, &s.data.notes, key)
+				purged[n.TaskID] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	removeFiles(files)
+	return len(purged), nil
+}
+
+func (s *MemoryStorage) RolloverOverdue(now time.Time) ([]DateChange, error) {
+	var moved []DateChange
+	err := s.write(func(tx *memoryTx) error {
+		var tasks []task.Task
+		for _, t := range s.data.tasksBefore(now.Format(date.DATE_FORMAT)) {
+			if t.Repeat != "" {
+				tasks = append(tasks, t)
+			}
+		}
+
+		rollover := s.As(RolloverAuthor).(*MemoryStorage)
+		moved = make([]DateChange, 0, len(tasks))
+		for _, t := range tasks {
+			newDate, err := date.NextDate(now, t.Date, t.Repeat)
+			if err != nil {
+				log.Printf("Не удалось перенести задачу %s: %v", t.ID, err)
+				continue
+			}
+			if err := rollover.moveTask(tx, t, newDate, 0, RevisionRollover); err != nil {
+				return err
+			}
+			moved = append(moved, DateChange{ID: t.ID, Title: t.Title, OldDate: t.Date, NewDate: newDate})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func (s *MemoryStorage) Reschedule(f TaskFilter, a RescheduleAction, now time.Time) (RescheduleResult, error) {
+	var result RescheduleResult
+	err := s.write(func(tx *memoryTx) error {
+		tasks := s.data.filterTasks(f, now)
+		today := now.Format(date.DATE_FORMAT)
+		result = RescheduleResult{Moved: make([]DateChange, 0, len(tasks)), Skipped: make([]DateChange, 0)}
+		for i, t := range tasks {
+			var newDate string
+			var err error
+			switch a.Type {
+			case RescheduleMove:
+				newDate = a.Date
+			case RescheduleShift:
+				newDate, err = date.AddDays(t.Date, a.Days)
+			case RescheduleSpread:
+				newDate, err = date.AddDays(today, i*a.Days/len(tasks))
+			}
+			if err != nil {
+				return err
+			}
+
+			if newDate == t.Date {
+				continue
+			}
+			change := DateChange{ID: t.ID, Title: t.Title, OldDate: t.Date, NewDate: newDate}
+			if pastDeadline(t, newDate) {
+				change.Deadline = t.Deadline
+				result.Skipped = append(result.Skipped, change)
+				continue
+			}
+			if err := s.moveTask(tx, t, newDate, 0, RevisionReschedule); err != nil {
+				return err
+			}
+			result.Moved = append(result.Moved, change)
+		}
+		return nil
+	})
+	if err != nil {
+		return RescheduleResult{}, err
+	}
+	return result, nil
+}
+
+func (s *MemoryStorage) recordRevision(tx *memoryTx, taskID string, before, after *task.Task, action string) {
+	var old, new task.Task
+	if before != nil {
+		old = *before
+	}
+	snapshot := old
+	if after != nil {
+		new = *after
+		snapshot = new
+	}
+	snapshot.ID = taskID
+	snapshot.Overdue = false
+
+	changes := task.Diff(old, new)
+	if len(changes) == 0 && action == RevisionUpdate {
+		return
+	}
+	s.recordUndo(taskID, before, after)
+
+	id := s.data.revisions.nextID()
+	put(tx, &s.data.revisions, id, Revision{
+		ID:        id,
+		TaskID:    taskID,
+		Author:    s.author,
+		Action:    action,
+		CreatedAt: time.Now().UTC(),
+		Changes:   cloneJSON(changes),
+		Task:      cloneJSON(snapshot),
+	})
+}
+
+func (s *MemoryStorage) recordUndo(taskID string, before, after *task.Task) {
+	if s.undo == nil {
+		return
+	}
+	step := UndoStep{TaskID: taskID}
+	if before != nil {
+		b := *before
+		step.Before = &b
+	}
+	if after != nil {
+		stored, _ := s.data.tasks.get(memoryKey(taskID))
+		step.Version = stored.Version
+	}
+	*s.undo = append(*s.undo, step)
+}
+
+func (s *MemoryStorage) GetRevisions(taskID string) ([]Revision, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	revisions := make([]Revision, 0)
+	all := s.data.revisions.sorted()
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].TaskID == taskID {
+			revisions = append(revisions, cloneRevision(all[i]))
+		}
+	}
+	return revisions, nil
+}
+
+func cloneRevision(r Revision) Revision {
+	r.Changes = cloneJSON(r.Changes)
+	r.Task = cloneJSON(r.Task)
+	return r
+}
+
+func (s *MemoryStorage) RevertTask(revisionID string) (task.Task, error) {
+	var reverted task.Task
+	err := s.write(func(tx *memoryTx) error {
+		r, ok := s.data.revisions.get(memoryKey(revisionID))
+		if !ok {
+			return sql.ErrNoRows
+		}
+		r = cloneRevision(r)
+
+		var before *task.Task
+		if current, err := s.data.getTask(r.TaskID); err == nil {
+			before = &current
+		}
+
+		s.restoreTask(tx, r.Task)
+		s.recordRevision(tx, r.TaskID, before, &r.Task, RevisionRevert)
+		reverted = r.Task
+		return nil
+	})
+	return reverted, err
+}
+
+func (s *MemoryStorage) Undo(steps []UndoStep) error {
+	s = s.Recording(nil).(*MemoryStorage)
+	return s.write(func(tx *memoryTx) error {
+		undone := make(map[string]bool)
+		for i := len(steps) - 1; i >= 0; i-- {
+			step := steps[i]
+
+			var current *task.Task
+			if t, err := s.data.getTask(step.TaskID); err == nil {
+				current = &t
+			}
+			if _, err := undoVersion(step, current, undone); err != nil {
+				return err
+			}
+
+			if step.Before == nil {
+				if current == nil {
+					continue
+				}
+				s.deleteTask(tx, step.TaskID)
+			} else {
+				s.restoreTask(tx, *step.Before)
+			}
+			s.recordRevision(tx, step.TaskID, current, step.Before, RevisionUndo)
+		}
+		return nil
+	})
+}
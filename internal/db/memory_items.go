@@ -0,0 +1,524 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+	"github.com/imbalaancing/go_final_project/internal/templates"
+)
+
+var errFieldExists = errors.New("поле с таким именем уже существует")
+
+func (s *MemoryStorage) StartTimer(id string, now time.Time) error {
+	return s.write(func(tx *memoryTx) error {
+		if _, ok := s.data.tasks.get(memoryKey(id)); !ok {
+			return sql.ErrNoRows
+		}
+		for _, e := range s.data.timeEntries.rows {
+			if e.TaskID == id && e.StoppedAt.IsZero() {
+				return ErrTimerRunning
+			}
+		}
+
+		key := s.data.timeEntries.nextID()
+		put(tx, &s.data.timeEntries, key, timeEntry{ID: key, TaskID: id, StartedAt: memoryTime(now)})
+		return nil
+	})
+}
+
+func (s *MemoryStorage) StopTimer(id string, now time.Time) error {
+	return s.write(func(tx *memoryTx) error {
+		if !s.stopTimer(tx, id, now) {
+			return ErrTimerNotRunning
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStorage) stopTimer(tx *memoryTx, id string, now time.Time) bool {
+	stopped := false
+	for key, e := range s.data.timeEntries.rows {
+		if e.TaskID == id && e.StoppedAt.IsZero() {
+			e.StoppedAt = memoryTime(now)
+			put(tx, &s.data.timeEntries, key, e)
+			stopped = true
+		}
+	}
+	return stopped
+}
+
+func (s *MemoryStorage) TimeReport(groupBy, from, to string, now time.Time) ([]TimeReportRow, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	tracked := make(map[string]time.Duration)
+	estimates := make(map[string]map[string]int)
+	deleted := make(map[string]task.Task)
+	for _, e := range s.data.timeEntries.sorted() {
+		t := s.data.reportTask(e.TaskID, deleted)
+		end := now
+		if !e.StoppedAt.IsZero() {
+			end = e.StoppedAt
+		}
+
+		day := e.StartedAt.Local().Format(date.DATE_FORMAT)
+		if (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+
+		var keys []string
+		switch groupBy {
+		case ReportByDay:
+			keys = []string{day}
+		case ReportByWeek:
+			year, week := e.StartedAt.Local().ISOWeek()
+			keys = []string{fmt.Sprintf("%d-W%02d", year, week)}
+		case ReportByTag:
+			keys = t.Tags
+			if len(keys) == 0 {
+				keys = []string{""}
+			}
+		}
+
+		for _, key := range keys {
+			tracked[key] += end.Sub(e.StartedAt)
+			if estimates[key] == nil {
+				estimates[key] = make(map[string]int)
+			}
+			estimates[key][e.TaskID] = t.Estimate
+		}
+	}
+
+	report := make([]TimeReportRow, 0, len(tracked))
+	for key, d := range tracked {
+		row := TimeReportRow{Key: key, Tracked: int(d.Round(time.Minute).Minutes())}
+		for _, e := range estimates[key] {
+			row.Estimate += e
+		}
+		report = append(report, row)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Key < report[j].Key })
+
+	return report, nil
+}
+
+func (s *MemoryStorage) InsertPomodoro(p PomodoroSession) (int64, error) {
+	var id int64
+	err := s.write(func(tx *memoryTx) error {
+		id = s.data.pomodoros.nextID()
+		put(tx, &s.data.pomodoros, id, PomodoroSession{
+			ID:        id,
+			TaskID:    p.TaskID,
+			Kind:      p.Kind,
+			StartedAt: memoryTime(p.StartedAt),
+			EndsAt:    memoryTime(p.EndsAt),
+		})
+		return nil
+	})
+	return id, err
+}
+
+func (s *MemoryStorage) FinishPomodoro(id int64, endedAt time.Time, completed bool) error {
+	return s.write(func(tx *memoryTx) error {
+		if p, ok := s.data.pomodoros.get(id); ok {
+			p.EndedAt = memoryTime(endedAt)
+			p.Completed = completed
+			put(tx, &s.data.pomodoros, id, p)
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStorage) ActivePomodoro() (PomodoroSession, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	sessions := s.data.pomodoros.sorted()
+	for i := len(sessions) - 1; i >= 0; i-- {
+		if sessions[i].EndedAt.IsZero() {
+			return sessions[i], nil
+		}
+	}
+	return PomodoroSession{}, sql.ErrNoRows
+}
+
+func (s *MemoryStorage) FocusSummary(day time.Time) ([]FocusSummaryRow, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	summary := make([]FocusSummaryRow, 0)
+	index := make(map[string]int)
+	deleted := make(map[string]task.Task)
+	for _, p := range s.data.pomodoros.sorted() {
+		if p.Kind != PomodoroFocus || !p.Completed || p.StartedAt.Before(start) || !p.StartedAt.Before(end) {
+			continue
+		}
+
+		i, ok := index[p.TaskID]
+		if !ok {
+			t := s.data.reportTask(p.TaskID, deleted)
+			i = len(summary)
+			index[p.TaskID] = i
+			summary = append(summary, FocusSummaryRow{TaskID: p.TaskID, Title: t.Title})
+		}
+		summary[i].Sessions++
+		summary[i].Minutes += int(p.EndedAt.Sub(p.StartedAt).Round(time.Minute).Minutes())
+	}
+	return summary, nil
+}
+
+func (s *MemoryStorage) SetAttachmentsDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	s.attachmentsDir = dir
+	return nil
+}
+
+func (s *MemoryStorage) InsertAttachment(a Attachment, content []byte) (int64, error) {
+	var id int64
+	var path string
+	err := s.write(func(tx *memoryTx) error {
+		if _, ok := s.data.tasks.get(memoryKey(a.TaskID)); !ok {
+			return sql.ErrNoRows
+		}
+
+		id = s.data.attachments.nextID()
+		stored := memoryAttachment{Attachment: Attachment{
+			ID:          id,
+			TaskID:      a.TaskID,
+			Name:        a.Name,
+			ContentType: a.ContentType,
+			Size:        int64(len(content)),
+			CreatedAt:   memoryTime(time.Now()),
+		}}
+		if s.attachmentsDir == "" {
+			stored.Data = slices.Clone(content)
+		} else {
+			path = filepath.Join(s.attachmentsDir, fmt.Sprintf("%s-%d", a.TaskID, id))
+			if err := os.WriteFile(path, content, 0o644); err != nil {
+				return err
+			}
+			stored.Path = path
+		}
+		put(tx, &s.data.attachments, id, stored)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *MemoryStorage) GetAttachments(taskID string) ([]Attachment, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	attachments := make([]Attachment, 0)
+	for _, a := range s.data.attachments.sorted() {
+		if a.TaskID == taskID && s.data.hasTask(taskID) {
+			attachments = append(attachments, a.Attachment)
+		}
+	}
+	return attachments, nil
+}
+
+func (s *MemoryStorage) GetAttachment(id string) (Attachment, []byte, error) {
+	s.data.mu.RLock()
+	a, ok := s.data.attachments.get(memoryKey(id))
+	ok = ok && s.data.hasTask(a.TaskID)
+	s.data.mu.RUnlock()
+	if !ok {
+		return Attachment{}, nil, sql.ErrNoRows
+	}
+
+	if a.Path != "" {
+		data, err := os.ReadFile(a.Path)
+		return a.Attachment, data, err
+	}
+	return a.Attachment, slices.Clone(a.Data), nil
+}
+
+func (s *MemoryStorage) DeleteAttachment(id string) (int64, error) {
+	var path string
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		a, ok := s.data.attachments.get(memoryKey(id))
+		if !ok || !s.data.hasTask(a.TaskID) {
+			return nil
+		}
+		remove(tx, &s.data.attachments, a.ID)
+		path = a.Path
+		rowsAffected = 1
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	removeFiles([]string{path})
+	return rowsAffected, nil
+}
+
+func (s *MemoryStorage) InsertNote(taskID, body string) (int64, error) {
+	var id int64
+	err := s.write(func(tx *memoryTx) error {
+		t, ok := s.data.tasks.get(memoryKey(taskID))
+		if !ok {
+			return sql.ErrNoRows
+		}
+
+		now := memoryTime(time.Now())
+		id = s.data.notes.nextID()
+		put(tx, &s.data.notes, id, Note{ID: id, TaskID: taskID, TaskDate: t.Date, Body: body, CreatedAt: now, UpdatedAt: now})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *MemoryStorage) GetNotes(taskID string) ([]Note, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	notes := make([]Note, 0)
+	for _, n := range s.data.notes.sorted() {
+		if n.TaskID == taskID && s.data.hasTask(taskID) {
+			notes = append(notes, n)
+		}
+	}
+	return notes, nil
+}
+
+func (s *MemoryStorage) GetNote(id string) (Note, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	n, ok := s.data.notes.get(memoryKey(id))
+	if !ok || !s.data.hasTask(n.TaskID) {
+		return Note{}, sql.ErrNoRows
+	}
+	return n, nil
+}
+
+func (s *MemoryStorage) UpdateNote(id, body string) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		n, ok := s.data.notes.get(memoryKey(id))
+		if !ok || !s.data.hasTask(n.TaskID) {
+			return nil
+		}
+		n.Body = body
+		n.UpdatedAt = memoryTime(time.Now())
+		put(tx, &s.data.notes, n.ID, n)
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+func (s *MemoryStorage) DeleteNote(id string) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		n, ok := s.data.notes.get(memoryKey(id))
+		if !ok || !s.data.hasTask(n.TaskID) {
+			return nil
+		}
+		remove(tx, &s.data.notes, n.ID)
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+func (s *MemoryStorage) GetFieldDefs() ([]task.FieldDef, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	defs := make([]task.FieldDef, 0)
+	for _, d := range s.data.fieldDefs.sorted() {
+		d.Options = slices.Clone(d.Options)
+		defs = append(defs, d)
+	}
+	return defs, nil
+}
+
+func (s *MemoryStorage) InsertFieldDef(d task.FieldDef) error {
+	return s.write(func(tx *memoryTx) error {
+		if _, ok := s.data.fieldDefs.get(d.Name); ok {
+			return errFieldExists
+		}
+		d.Options = slices.Clone(d.Options)
+		put(tx, &s.data.fieldDefs, d.Name, d)
+		return nil
+	})
+}
+
+func (s *MemoryStorage) UpdateFieldDef(d task.FieldDef) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		stored, ok := s.data.fieldDefs.get(d.Name)
+		if !ok {
+			return nil
+		}
+		stored.Options = slices.Clone(d.Options)
+		stored.Tag = d.Tag
+		put(tx, &s.data.fieldDefs, d.Name, stored)
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+// DeleteFieldDef удаляет поле вместе с его значениями у всех задач. Как и в
+// Storage, удаление значений не записывается в историю задач.
+func (s *MemoryStorage) DeleteFieldDef(name string) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		if remove(tx, &s.data.fieldDefs, name) {
+			rowsAffected = 1
+		}
+		for _, t := range s.data.tasks.rows {
+			if _, ok := t.Fields[name]; ok {
+				t = cloneJSON(t)
+				delete(t.Fields, name)
+				s.putTask(tx, t)
+			}
+		}
+		return nil
+	})
+	return rowsAffected, err
+}
+
+func (s *MemoryStorage) InsertTemplate(t templates.Template) (int64, error) {
+	var id int64
+	err := s.write(func(tx *memoryTx) error {
+		id = s.data.templates.nextID()
+		t.ID = strconv.FormatInt(id, 10)
+		put(tx, &s.data.templates, id, cloneJSON(t))
+		return nil
+	})
+	return id, err
+}
+
+func (s *MemoryStorage) GetTemplates() ([]templates.Template, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	list := make([]templates.Template, 0)
+	for _, t := range s.data.templates.sorted() {
+		list = append(list, cloneJSON(t))
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *MemoryStorage) GetTemplate(id string) (templates.Template, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	t, ok := s.data.templates.get(memoryKey(id))
+	if !ok {
+		return templates.Template{}, sql.ErrNoRows
+	}
+	return cloneJSON(t), nil
+}
+
+func (s *MemoryStorage) UpdateTemplate(t templates.Template) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		key := memoryKey(t.ID)
+		if _, ok := s.data.templates.get(key); !ok {
+			return nil
+		}
+		t.ID = strconv.FormatInt(key, 10)
+		put(tx, &s.data.templates, key, cloneJSON(t))
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+func (s *MemoryStorage) DeleteTemplate(id string) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		if remove(tx, &s.data.templates, memoryKey(id)) {
+			rowsAffected = 1
+		}
+		return nil
+	})
+	return rowsAffected, err
+}
+
+func (s *MemoryStorage) InsertView(v View) (int64, error) {
+	var id int64
+	err := s.write(func(tx *memoryTx) error {
+		id = s.data.views.nextID()
+		v.ID = strconv.FormatInt(id, 10)
+		put(tx, &s.data.views, id, cloneJSON(v))
+		return nil
+	})
+	return id, err
+}
+
+func (s *MemoryStorage) GetViews() ([]View, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	list := make([]View, 0)
+	for _, v := range s.data.views.sorted() {
+		list = append(list, cloneJSON(v))
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (s *MemoryStorage) GetView(id string) (View, error) {
+	s.data.mu.RLock()
+	defer s.data.mu.RUnlock()
+
+	v, ok := s.data.views.get(memoryKey(id))
+	if !ok {
+		return View{}, sql.ErrNoRows
+	}
+	return cloneJSON(v), nil
+}
+
+func (s *MemoryStorage) UpdateView(v View) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		key := memoryKey(v.ID)
+		if _, ok := s.data.views.get(key); !ok {
+			return nil
+		}
+		v.ID = strconv.FormatInt(key, 10)
+		put(tx, &s.data.views, key, cloneJSON(v))
+		rowsAffected = 1
+		return nil
+	})
+	return rowsAffected, err
+}
+
+func (s *MemoryStorage) DeleteView(id string) (int64, error) {
+	var rowsAffected int64
+	err := s.write(func(tx *memoryTx) error {
+		if remove(tx, &s.data.views, memoryKey(id)) {
+			rowsAffected = 1
+		}
+		return nil
+	})
+	return rowsAffected, err
+}
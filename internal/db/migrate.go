@@ -0,0 +1,336 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Миграции схемы лежат в migrations/<база>/ в файлах NNNN_имя.up.sql и
+// NNNN_имя.down.sql и встроены в бинарный файл. Номера миграций идут подряд
+// с 1, а схема меняется только новой миграцией в конце: применённые миграции
+// не редактируются. Применённые номера записываются в schema_migrations.
+//
+// Индекс полнотекстового поиска SQLite в миграции не входит: он зависит от
+// сборки (см. initSearch) и строится заново из таблицы scheduler.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrSchemaTooNew означает, что база данных изменена более новой версией
+// сервера, чем запущенная, и работать с ней нельзя.
+var ErrSchemaTooNew = errors.New("схема базы данных новее, чем поддерживает эта версия сервера")
+
+// Migration — одна версия схемы: SQL для перехода на неё и для отката.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus — миграция и время её применения; AppliedAt пуст, если
+// миграция не применена. Name пуст у применённых миграций, которых нет в этой
+// версии сервера.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt string
+}
+
+// Migrator применяет и откатывает миграции базы данных.
+type Migrator struct {
+	db         *sqlDB
+	migrations []Migration
+	// lock выполняется в начале транзакции каждой миграции, чтобы несколько
+	// серверов, запущенных одновременно, не применили её дважды.
+	lock string
+	// legacy приводит базу, созданную до появления миграций, к исходной схеме.
+	legacy func(db *sql.DB) error
+}
+
+func NewSQLiteMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations("migrations/sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: &sqlDB{DB: db, dialect: sqliteDialect}, migrations: migrations, legacy: upgradeLegacySchema}, nil
+}
+
+func NewPostgresMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations("migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         &sqlDB{DB: db, dialect: postgresDialect},
+		migrations: migrations,
+		lock:       `SELECT pg_advisory_xact_lock(` + strconv.Itoa(postgresLockID) + `)`,
+	}, nil
+}
+
+// loadMigrations читает миграции из каталога dir и проверяет, что номера
+// идут подряд и у каждой миграции есть up и down.
+func loadMigrations(dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []Migration
+	for _, e := range entries {
+		name, direction, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".sql"), ".")
+		number, title, ok2 := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !ok2 || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("неверное имя файла миграции: %s", e.Name())
+		}
+		body, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		// Файлы отсортированы по имени, поэтому up и down одной миграции идут подряд.
+		if n := len(migrations); n == 0 || migrations[n-1].Version != version {
+			if version != n+1 {
+				return nil, fmt.Errorf("миграция %s: ожидался номер %d", e.Name(), n+1)
+			}
+			migrations = append(migrations, Migration{Version: version, Name: title})
+		}
+		m := &migrations[len(migrations)-1]
+		if m.Name != title {
+			return nil, fmt.Errorf("миграция %s: у файлов up и down разные имена", e.Name())
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+	for _, m := range migrations {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("у миграции %d_%s нет файла up или down", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest возвращает номер последней миграции, известной этой версии сервера.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version возвращает номер последней применённой миграции, 0 для пустой базы.
+func (m *Migrator) Version() (int, error) {
+	if err := m.createTable(); err != nil {
+		return 0, err
+	}
+	var version int
+	err := m.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Check возвращает ErrSchemaTooNew, если в базе применены миграции, которых
+// эта версия сервера не знает.
+func (m *Migrator) Check() error {
+	_, err := m.checkedVersion()
+	return err
+}
+
+func (m *Migrator) checkedVersion() (int, error) {
+	version, err := m.Version()
+	if err != nil {
+		return 0, err
+	}
+	if version > m.Latest() {
+		return 0, fmt.Errorf("%w: версия схемы %d, поддерживается до %d", ErrSchemaTooNew, version, m.Latest())
+	}
+	return version, nil
+}
+
+// Up применяет все недостающие миграции по порядку и возвращает их число.
+func (m *Migrator) Up() (int, error) {
+	version, err := m.checkedVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version == 0 && m.legacy != nil {
+		if err := m.legacy(m.db.DB); err != nil {
+			return 0, err
+		}
+	}
+
+	applied := 0
+	for _, migration := range m.migrations[version:] {
+		done, err := m.apply(migration, true)
+		if err != nil {
+			return applied, fmt.Errorf("миграция %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		if done {
+			applied++
+			log.Printf("Применена миграция %d_%s.", migration.Version, migration.Name)
+		}
+	}
+	return applied, nil
+}
+
+// Down откатывает последнюю применённую миграцию и возвращает её номер, 0 —
+// если откатывать нечего.
+func (m *Migrator) Down() (int, error) {
+	version, err := m.checkedVersion()
+	if err != nil || version == 0 {
+		return 0, err
+	}
+
+	migration := m.migrations[version-1]
+	if _, err := m.apply(migration, false); err != nil {
+		return 0, fmt.Errorf("откат миграции %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("Откачена миграция %d_%s.", migration.Version, migration.Name)
+	return version, nil
+}
+
+// Status возвращает все известные и применённые миграции по порядку номеров.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query(`SELECT version, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	latest := m.Latest()
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+		latest = max(latest, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	list := make([]MigrationStatus, 0, latest)
+	for version := 1; version <= latest; version++ {
+		s := MigrationStatus{Version: version, AppliedAt: applied[version]}
+		if version <= m.Latest() {
+			s.Name = m.migrations[version-1].Name
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+func (m *Migrator) createTable() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	applied_at TEXT NOT NULL
+)`)
+	return err
+}
+
+// apply применяет (up) или откатывает миграцию в одной транзакции с записью в
+// schema_migrations. Миграция, которую уже применил или откатил другой
+// процесс, пропускается.
+func (m *Migrator) apply(migration Migration, up bool) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if m.lock != "" {
+		if _, err := tx.Exec(m.lock); err != nil {
+			return false, err
+		}
+	}
+	var count int
+	err = tx.QueryRow(`SELECT count(*) FROM schema_migrations WHERE version = ?`, migration.Version).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	if (count > 0) == up {
+		return false, nil
+	}
+
+	// SQL миграций пишется сразу для своей базы и не переводится диалектом.
+	if up {
+		if _, err := tx.Tx.Exec(migration.Up); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			migration.Version, time.Now().UTC().Format(time.RFC3339))
+	} else {
+		if _, err := tx.Tx.Exec(migration.Down); err != nil {
+			return false, err
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// legacyColumns перечисляет столбцы, которые добавлялись в базы, созданные до
+// появления миграций. В исходной схеме они уже есть, но CREATE TABLE IF NOT
+// EXISTS не добавляет их в существующую таблицу.
+var legacyColumns = []struct {
+	table, column, definition string
+}{
+	{"task_meta", "estimate", "INTEGER NOT NULL DEFAULT 0"},
+	{"task_meta", "priority", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// upgradeLegacySchema добавляет недостающие столбцы в базу SQLite, созданную
+// до появления миграций. В новой базе таблиц ещё нет, и она не меняется.
+func upgradeLegacySchema(db *sql.DB) error {
+	var tables int
+	err := db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'task_meta'`).Scan(&tables)
+	if err != nil || tables == 0 {
+		return err
+	}
+	for _, c := range legacyColumns {
+		if err := addColumn(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addColumn(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	return err
+}
@@ -0,0 +1,129 @@
+package db_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+func TestSQLiteMigrations(t *testing.T) {
+	skipWithoutSQLite(t)
+	database, err := db.OpenDB(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	migrator, err := db.NewSQLiteMigrator(database)
+	require.NoError(t, err)
+	testMigrations(t, database, migrator)
+}
+
+func TestPostgresMigrations(t *testing.T) {
+	if os.Getenv("TODO_TEST_DATABASE_URL") == "" {
+		t.Skip("TODO_TEST_DATABASE_URL не задана")
+	}
+	database, err := db.OpenPostgres(postgresTestDSN(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	migrator, err := db.NewPostgresMigrator(database)
+	require.NoError(t, err)
+	testMigrations(t, database, migrator)
+}
+
+// testMigrations применяет все миграции, откатывает их по одной до пустой
+// базы и применяет снова: так проверяется каждая пара up и down.
+func testMigrations(t *testing.T, database *sql.DB, migrator *db.Migrator) {
+	n, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), n)
+
+	n, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	list, err := migrator.Status()
+	require.NoError(t, err)
+	require.Len(t, list, migrator.Latest())
+	for _, m := range list {
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.AppliedAt)
+	}
+
+	for version := migrator.Latest(); version > 0; version-- {
+		reverted, err := migrator.Down()
+		require.NoError(t, err)
+		assert.Equal(t, version, reverted)
+	}
+	reverted, err := migrator.Down()
+	require.NoError(t, err)
+	assert.Zero(t, reverted)
+	_, err = database.Exec(`SELECT count(*) FROM scheduler`)
+	assert.Error(t, err)
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	version, err := migrator.Version()
+	require.NoError(t, err)
+	assert.Equal(t, migrator.Latest(), version)
+
+	// Схему, изменённую более новой версией сервера, трогать нельзя.
+	_, err = database.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (` + strconv.Itoa(version+1) + `, '')`)
+	require.NoError(t, err)
+	assert.ErrorIs(t, migrator.Check(), db.ErrSchemaTooNew)
+	_, err = migrator.Up()
+	assert.ErrorIs(t, err, db.ErrSchemaTooNew)
+	_, err = migrator.Down()
+	assert.ErrorIs(t, err, db.ErrSchemaTooNew)
+}
+
+func TestInitDBSchemaTooNew(t *testing.T) {
+	skipWithoutSQLite(t)
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	database, err := db.InitDB(path)
+	require.NoError(t, err)
+	_, err = database.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (1000, '')`)
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+
+	_, err = db.InitDB(path)
+	assert.ErrorIs(t, err, db.ErrSchemaTooNew)
+}
+
+// TestInitDBLegacy проверяет, что база, созданная до появления миграций,
+// получает недостающие столбцы и сохраняет данные.
+func TestInitDBLegacy(t *testing.T) {
+	skipWithoutSQLite(t)
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	database, err := db.OpenDB(path)
+	require.NoError(t, err)
+	_, err = database.Exec(`
+CREATE TABLE scheduler (id INTEGER PRIMARY KEY AUTOINCREMENT, date TEXT NOT NULL, title TEXT NOT NULL, comment TEXT, repeat TEXT);
+CREATE TABLE task_meta (task_id INTEGER PRIMARY KEY, deadline TEXT NOT NULL DEFAULT '');
+INSERT INTO scheduler (date, title, comment, repeat) VALUES ('20240101', 'Старая задача', '', '');
+INSERT INTO task_meta (task_id, deadline) VALUES (1, '20240105');`)
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+
+	database, err = db.InitDB(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	s := db.NewTaskStorage(database)
+
+	got, err := s.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, "Старая задача", got.Title)
+	assert.Equal(t, "20240105", got.Deadline)
+
+	got.Priority = task.PriorityHigh
+	_, err = s.UpdateTask(got)
+	require.NoError(t, err)
+	got, err = s.GetTask("1")
+	require.NoError(t, err)
+	assert.Equal(t, task.PriorityHigh, got.Priority)
+}
@@ -0,0 +1,106 @@
+-- Схема PostgreSQL повторяет схему SQLite: Storage выполняет одни и те же
+-- запросы в обеих базах (см. dialect.go). Идентификаторы выдаются столбцами
+-- identity, содержимое вложений хранится в bytea.
+CREATE TABLE scheduler (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	date TEXT NOT NULL,
+	title TEXT NOT NULL,
+	comment TEXT,
+	repeat TEXT
+);
+CREATE INDEX idx_date ON scheduler(date);
+
+CREATE TABLE task_meta (
+	task_id BIGINT PRIMARY KEY,
+	deadline TEXT NOT NULL DEFAULT '',
+	estimate INTEGER NOT NULL DEFAULT 0,
+	priority INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_deadline ON task_meta(deadline);
+
+CREATE TABLE task_tags (
+	task_id BIGINT NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (task_id, tag)
+);
+CREATE INDEX idx_tag ON task_tags(tag);
+
+CREATE TABLE time_entries (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	started_at TEXT NOT NULL,
+	stopped_at TEXT
+);
+CREATE INDEX idx_time_entries_task ON time_entries(task_id);
+
+CREATE TABLE pomodoro_sessions (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	kind TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	ends_at TEXT NOT NULL,
+	ended_at TEXT,
+	completed INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_pomodoro_started ON pomodoro_sessions(started_at);
+
+CREATE TABLE attachments (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size BIGINT NOT NULL,
+	path TEXT NOT NULL DEFAULT '',
+	data BYTEA,
+	created_at TEXT NOT NULL
+);
+CREATE INDEX idx_attachments_task ON attachments(task_id);
+
+CREATE TABLE task_notes (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	task_date TEXT NOT NULL,
+	body TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX idx_task_notes_task ON task_notes(task_id);
+
+CREATE TABLE custom_fields (
+	name TEXT PRIMARY KEY,
+	type TEXT NOT NULL,
+	options TEXT NOT NULL DEFAULT 'null',
+	tag TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE task_fields (
+	task_id BIGINT NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (task_id, name)
+);
+CREATE INDEX idx_task_fields_name ON task_fields(name, value);
+
+CREATE TABLE task_revisions (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	task_id BIGINT NOT NULL,
+	author TEXT NOT NULL,
+	action TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	changes TEXT NOT NULL,
+	snapshot TEXT NOT NULL
+);
+CREATE INDEX idx_task_revisions_task ON task_revisions(task_id);
+
+CREATE TABLE task_templates (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	tasks TEXT NOT NULL
+);
+
+CREATE TABLE task_views (
+	id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+	name TEXT NOT NULL,
+	params TEXT NOT NULL DEFAULT '{}'
+);
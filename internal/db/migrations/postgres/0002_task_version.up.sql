@@ -0,0 +1,4 @@
+-- Версия задачи растёт при каждом её изменении и нужна для условных запросов
+-- (If-Match). Задачи без строки в task_meta получают её здесь.
+ALTER TABLE task_meta ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+INSERT INTO task_meta (task_id) SELECT id FROM scheduler WHERE id NOT IN (SELECT task_id FROM task_meta);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS task_views;
+DROP TABLE IF EXISTS task_templates;
+DROP TABLE IF EXISTS task_revisions;
+DROP TABLE IF EXISTS task_fields;
+DROP TABLE IF EXISTS custom_fields;
+DROP TABLE IF EXISTS task_notes;
+DROP TABLE IF EXISTS attachments;
+DROP TABLE IF EXISTS pomodoro_sessions;
+DROP TABLE IF EXISTS time_entries;
+DROP TABLE IF EXISTS task_tags;
+DROP TABLE IF EXISTS task_meta;
+DROP TABLE IF EXISTS scheduler;
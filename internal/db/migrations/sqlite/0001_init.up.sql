@@ -0,0 +1,105 @@
+-- Исходная схема. Таблицы создаются с IF NOT EXISTS, чтобы миграция
+-- применялась и к базам, созданным до появления миграций.
+CREATE TABLE IF NOT EXISTS scheduler (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	date TEXT NOT NULL,
+	title TEXT NOT NULL,
+	comment TEXT,
+	repeat TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_date ON scheduler(date);
+
+CREATE TABLE IF NOT EXISTS task_meta (
+	task_id INTEGER PRIMARY KEY,
+	deadline TEXT NOT NULL DEFAULT '',
+	estimate INTEGER NOT NULL DEFAULT 0,
+	priority INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_deadline ON task_meta(deadline);
+
+CREATE TABLE IF NOT EXISTS task_tags (
+	task_id INTEGER NOT NULL,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (task_id, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_tag ON task_tags(tag);
+
+CREATE TABLE IF NOT EXISTS time_entries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	started_at TEXT NOT NULL,
+	stopped_at TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_time_entries_task ON time_entries(task_id);
+
+CREATE TABLE IF NOT EXISTS pomodoro_sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	ends_at TEXT NOT NULL,
+	ended_at TEXT,
+	completed INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_pomodoro_started ON pomodoro_sessions(started_at);
+
+CREATE TABLE IF NOT EXISTS attachments (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	size INTEGER NOT NULL,
+	path TEXT NOT NULL DEFAULT '',
+	data BLOB,
+	created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_task ON attachments(task_id);
+
+CREATE TABLE IF NOT EXISTS task_notes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	task_date TEXT NOT NULL,
+	body TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_task_notes_task ON task_notes(task_id);
+
+CREATE TABLE IF NOT EXISTS custom_fields (
+	name TEXT PRIMARY KEY,
+	type TEXT NOT NULL,
+	options TEXT NOT NULL DEFAULT 'null',
+	tag TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS task_fields (
+	task_id INTEGER NOT NULL,
+	name TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (task_id, name)
+);
+CREATE INDEX IF NOT EXISTS idx_task_fields_name ON task_fields(name, value);
+
+CREATE TABLE IF NOT EXISTS task_revisions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	task_id INTEGER NOT NULL,
+	author TEXT NOT NULL,
+	action TEXT NOT NULL,
+	created_at TEXT NOT NULL,
+	changes TEXT NOT NULL,
+	snapshot TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_task_revisions_task ON task_revisions(task_id);
+
+CREATE TABLE IF NOT EXISTS task_templates (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	tasks TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS task_views (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL,
+	params TEXT NOT NULL DEFAULT '{}'
+);
@@ -0,0 +1 @@
+ALTER TABLE task_meta DROP COLUMN version;
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"time"
+)
+
+// Note — запись в журнале задачи. TaskDate — дата задачи на момент записи, чтобы
+// у повторяющейся задачи было видно, к какому выполнению относится заметка.
+type Note struct {
+	ID        int64     `json:"id"`
+	TaskID    string    `json:"task_id"`
+	TaskDate  string    `json:"task_date"`
+	Body      string    `json:"body"`
+	HTML      string    `json:"html"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Storage) InsertNote(taskID, body string) (int64, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var taskDate string
+	if err := tx.QueryRow(`SELECT date FROM scheduler WHERE id = ?`, taskID).Scan(&taskDate); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var id int64
+	err = tx.QueryRow(`INSERT INTO task_notes (task_id, task_date, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?) RETURNING id`,
+		taskID, taskDate, body, now, now).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func (s *Storage) GetNotes(taskID string) ([]Note, error) {
+	rows, err := s.db.Query(`SELECT id, task_id, task_date, body, created_at, updated_at
+FROM task_notes WHERE task_id = ?`+liveTask+` ORDER BY id`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make([]Note, 0)
+	for rows.Next() {
+		n, err := scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func (s *Storage) GetNote(id string) (Note, error) {
+	return scanNote(s.db.QueryRow(`SELECT id, task_id, task_date, body, created_at, updated_at
+FROM task_notes WHERE id = ?`+liveTask, id))
+}
+
+func (s *Storage) UpdateNote(id, body string) (int64, error) {
+	res, err := s.db.Exec(`UPDATE task_notes SET body = ?, updated_at = ? WHERE id = ?`+liveTask,
+		body, time.Now().UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) DeleteNote(id string) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM task_notes WHERE id = ?`+liveTask, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanNote(row rowScanner) (Note, error) {
+	var n Note
+	var created, updated string
+	if err := row.Scan(&n.ID, &n.TaskID, &n.TaskDate, &n.Body, &created, &updated); err != nil {
+		return n, err
+	}
+	var err error
+	if n.CreatedAt, err = time.Parse(time.RFC3339, created); err != nil {
+		return n, err
+	}
+	n.UpdatedAt, err = time.Parse(time.RFC3339, updated)
+	return n, err
+}
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// MaxTaskLimit — наибольший размер страницы списка задач.
+const MaxTaskLimit = 500
+
+// ErrInvalidCursor означает, что курсор повреждён или получен для другой сортировки.
+var ErrInvalidCursor = errors.New("неверный курсор")
+
+// Cursor указывает место в списке задач, с которого начинается страница.
+// Клиенту он передаётся непрозрачной строкой (см. String и ParseCursor).
+type Cursor struct {
+	// Key — значения ключа сортировки задачи, после которой (или, если Back,
+	// перед которой) начинается страница.
+	Key []any `json:"k,omitempty"`
+	// Offset — число пропускаемых задач, для результатов поиска, упорядоченных
+	// по релевантности.
+	Offset int  `json:"o,omitempty"`
+	Back   bool `json:"b,omitempty"`
+}
+
+// Page задаёт запрашиваемую страницу.
+type Page struct {
+	Limit  int
+	Cursor *Cursor
+	// Total запрашивает общее число задач, подходящих под фильтр.
+	Total bool
+}
+
+// TaskPage — страница списка задач. Next и Prev равны nil, если дальше или
+// раньше задач нет; Total равен -1, если не запрошен.
+type TaskPage struct {
+	Tasks []task.Task
+	Next  *Cursor
+	Prev  *Cursor
+	Total int
+}
+
+func (c Cursor) String() string {
+	body, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(body)
+}
+
+// ParseCursor разбирает курсор, полученный от клиента.
+func ParseCursor(s string) (*Cursor, error) {
+	body, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var c Cursor
+	if err := dec.Decode(&c); err != nil || c.Offset < 0 {
+		return nil, ErrInvalidCursor
+	}
+	// Числа ключа передаются в SQLite как числа, а не как строки json.Number.
+	for i, v := range c.Key {
+		if n, ok := v.(json.Number); ok {
+			if k, err := n.Int64(); err == nil {
+				c.Key[i] = k
+			} else if k, err := n.Float64(); err == nil {
+				c.Key[i] = k
+			}
+		}
+	}
+	return &c, nil
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 {
+		return TaskLimit
+	}
+	return min(p.Limit, MaxTaskLimit)
+}
+
+// GetTaskPage возвращает страницу задач, отобранных фильтром f. Страницы
+// отсчитываются по ключу сортировки, поэтому добавление и удаление задач не
+// сдвигает уже полученные страницы.
+func (s *Storage) GetTaskPage(f TaskFilter, p Page) (TaskPage, error) {
+	now := time.Now()
+	where, args := f.where(now)
+	keys, keyArgs, desc := f.orderKeys()
+
+	columns := make([]string, len(keys))
+	for i := range keys {
+		columns[i] = fmt.Sprintf("k%d", i)
+	}
+
+	// Назад страница выбирается в обратном порядке и затем разворачивается.
+	back := p.Cursor != nil && p.Cursor.Back
+	direction, compare := ` ASC`, `>`
+	if desc != back {
+		direction, compare = ` DESC`, `<`
+	}
+
+	var query strings.Builder
+	query.WriteString(`SELECT * FROM (SELECT ` + taskColumns)
+	for i, key := range keys {
+		query.WriteString(`, ` + key + ` AS ` + columns[i])
+	}
+	query.WriteString(`
+FROM scheduler s LEFT JOIN task_meta m ON m.task_id = s.id` + where + `) AS page`)
+	queryArgs := append(append([]any{}, keyArgs...), args...)
+
+	if p.Cursor != nil {
+		if len(p.Cursor.Key) != len(keys) {
+			return TaskPage{}, ErrInvalidCursor
+		}
+		query.WriteString(` WHERE (` + strings.Join(columns, `, `) + `) ` + compare +
+			` (?` + strings.Repeat(`, ?`, len(keys)-1) + `)`)
+		queryArgs = append(queryArgs, p.Cursor.Key...)
+	}
+	query.WriteString(` ORDER BY ` + strings.Join(columns, direction+`, `) + direction + ` LIMIT ?`)
+	limit := p.limit()
+	queryArgs = append(queryArgs, limit+1)
+
+	rows, err := s.db.Query(query.String(), queryArgs...)
+	if err != nil {
+		return TaskPage{}, err
+	}
+	defer rows.Close()
+
+	var tasks []task.Task
+	var pageKeys [][]any
+	for rows.Next() {
+		key := make([]any, len(keys))
+		dest := make([]any, len(keys))
+		for i := range key {
+			dest[i] = &key[i]
+		}
+		t, err := scanTask(rows, dest...)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		for i, v := range key {
+			if b, ok := v.([]byte); ok {
+				key[i] = string(b)
+			}
+		}
+		t.SetOverdue(now)
+		tasks = append(tasks, t)
+		pageKeys = append(pageKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return TaskPage{}, err
+	}
+
+	page := keysetPage(tasks, pageKeys, p, limit)
+	if p.Total {
+		err := s.db.QueryRow(`SELECT count(*) FROM scheduler s LEFT JOIN task_meta m ON m.task_id = s.id`+where, args...).
+			Scan(&page.Total)
+		if err != nil {
+			return TaskPage{}, err
+		}
+	}
+	return page, nil
+}
+
+// keysetPage составляет страницу из задач tasks с ключами сортировки keys,
+// выбранных в порядке листания: не больше limit+1 задачи после курсора p.Cursor.
+func keysetPage(tasks []task.Task, keys [][]any, p Page, limit int) TaskPage {
+	back := p.Cursor != nil && p.Cursor.Back
+	more := len(tasks) > limit
+	if more {
+		tasks, keys = tasks[:limit], keys[:limit]
+	}
+	if back {
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	page := TaskPage{Tasks: tasks, Total: -1}
+	if page.Tasks == nil {
+		page.Tasks = make([]task.Task, 0)
+	}
+	if len(tasks) > 0 {
+		// Вперёд можно листать, если там остались задачи или страница получена
+		// листанием назад; назад — наоборот.
+		if (!back && more) || (back && p.Cursor != nil) {
+			page.Next = &Cursor{Key: keys[len(keys)-1]}
+		}
+		if (back && more) || (!back && p.Cursor != nil) {
+			page.Prev = &Cursor{Key: keys[0], Back: true}
+		}
+	}
+	return page
+}
+
+// offsetPage возвращает страницу по смещению для списка, в котором есть ещё
+// задачи после tasks (more). Используется для результатов поиска.
+func offsetPage(tasks []task.Task, offset int, more bool, limit int) TaskPage {
+	page := TaskPage{Tasks: tasks, Total: -1}
+	if more {
+		page.Next = &Cursor{Offset: offset + limit}
+	}
+	if offset > 0 {
+		page.Prev = &Cursor{Offset: max(offset-limit, 0)}
+	}
+	return page
+}
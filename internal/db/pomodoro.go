@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	PomodoroFocus = "focus"
+	PomodoroBreak = "break"
+)
+
+// PomodoroSession — интервал фокуса или перерыва. Пока сессия идёт, EndedAt пуст.
+type PomodoroSession struct {
+	ID        int64     `json:"id"`
+	TaskID    string    `json:"task_id"`
+	Kind      string    `json:"kind"`
+	StartedAt time.Time `json:"started_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	EndedAt   time.Time `json:"-"`
+	Completed bool      `json:"-"`
+}
+
+// FocusSummaryRow — итог фокус-сессий по задаче за день.
+type FocusSummaryRow struct {
+	TaskID   string `json:"task_id"`
+	Title    string `json:"title"`
+	Sessions int    `json:"sessions"`
+	Minutes  int    `json:"minutes"`
+}
+
+func (s *Storage) InsertPomodoro(p PomodoroSession) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO pomodoro_sessions (task_id, kind, started_at, ends_at) VALUES (?, ?, ?, ?) RETURNING id`,
+		p.TaskID, p.Kind, p.StartedAt.UTC().Format(time.RFC3339), p.EndsAt.UTC().Format(time.RFC3339)).Scan(&id)
+	return id, err
+}
+
+func (s *Storage) FinishPomodoro(id int64, endedAt time.Time, completed bool) error {
+	done := 0
+	if completed {
+		done = 1
+	}
+	_, err := s.db.Exec(`UPDATE pomodoro_sessions SET ended_at = ?, completed = ? WHERE id = ?`,
+		endedAt.UTC().Format(time.RFC3339), done, id)
+	return err
+}
+
+// ActivePomodoro возвращает незавершённую сессию или sql.ErrNoRows.
+func (s *Storage) ActivePomodoro() (PomodoroSession, error) {
+	var p PomodoroSession
+	var started, ends string
+	err := s.db.QueryRow(`SELECT id, task_id, kind, started_at, ends_at FROM pomodoro_sessions
+WHERE ended_at IS NULL ORDER BY id DESC LIMIT 1`).Scan(&p.ID, &p.TaskID, &p.Kind, &started, &ends)
+	if err != nil {
+		return p, err
+	}
+	if p.StartedAt, err = time.Parse(time.RFC3339, started); err != nil {
+		return p, err
+	}
+	p.EndsAt, err = time.Parse(time.RFC3339, ends)
+	return p, err
+}
+
+// FocusSummary подсчитывает завершённые фокус-сессии по задачам за день day.
+func (s *Storage) FocusSummary(day time.Time) ([]FocusSummaryRow, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	rows, err := s.db.Query(`SELECT p.task_id, s.title, p.started_at, p.ended_at
+FROM pomodoro_sessions p LEFT JOIN scheduler s ON s.id = p.task_id
+WHERE p.kind = ? AND p.completed = 1 AND p.started_at >= ? AND p.started_at < ?
+ORDER BY p.id`, PomodoroFocus, start.UTC().Format(time.RFC3339), start.AddDate(0, 0, 1).UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := make([]FocusSummaryRow, 0)
+	index := make(map[string]int)
+	var deleted []int
+	for rows.Next() {
+		var taskID, started string
+		var title, ended sql.NullString
+		if err := rows.Scan(&taskID, &title, &started, &ended); err != nil {
+			return nil, err
+		}
+		from, err := time.Parse(time.RFC3339, started)
+		if err != nil {
+			return nil, err
+		}
+		to, err := time.Parse(time.RFC3339, ended.String)
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := index[taskID]
+		if !ok {
+			i = len(summary)
+			index[taskID] = i
+			summary = append(summary, FocusSummaryRow{TaskID: taskID, Title: title.String})
+			if !title.Valid {
+				deleted = append(deleted, i)
+			}
+		}
+		summary[i].Sessions++
+		summary[i].Minutes += int(to.Sub(from).Round(time.Minute).Minutes())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// Название удалённой задачи берётся из её последней ревизии.
+	for _, i := range deleted {
+		t, err := s.lastSnapshot(summary[i].TaskID)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		summary[i].Title = t.Title
+	}
+	return summary, nil
+}
@@ -0,0 +1,475 @@
+package db
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+)
+
+// Query — условие отбора задач на языке запросов, например
+// `date>=20250101 repeat:w tag:work has:comment -title:draft`.
+//
+// Запрос состоит из условий, разделённых пробелами; задача подходит, если
+// выполнены все условия. Условие имеет вид <поле><оператор><значение>, где
+// оператор — одно из ":", "=", "!=", ">", ">=", "<", "<=". Минус перед
+// условием его отрицает, слово без поля ищется в заголовке и комментарии,
+// значение с пробелами берётся в кавычки.
+//
+// Каждое условие разбирается в SQL с параметрами и в равносильную проверку
+// задачи в Go для хранилищ без SQL. Запрос хранится вместе с исходным текстом,
+// поэтому в JSON он передаётся строкой.
+type Query struct {
+	source string
+	conds  []queryCond
+}
+
+type queryCond struct {
+	sql   string
+	args  []any
+	match func(r *taskRow) bool
+}
+
+// queryFields описывает поля запроса и допустимые для них операторы.
+var queryFields = map[string]func(op, value string) (queryCond, error){
+	"date":     dateCond(`s.date`, func(r *taskRow) string { return r.Date }),
+	"deadline": dateCond(`m.deadline`, func(r *taskRow) string { return r.Deadline }),
+	"title":    textCond(`s.title`, func(r *taskRow) string { return r.Title }),
+	"comment":  textCond(`s.comment`, func(r *taskRow) string { return r.Comment }),
+	"repeat":   repeatCond,
+	"tag":      tagCond,
+	"priority": numberCond(`COALESCE(m.priority, 0)`, func(r *taskRow) int { return r.Priority }, priorityNames),
+	"estimate": numberCond(`COALESCE(m.estimate, 0)`, func(r *taskRow) int { return r.Estimate }, nil),
+	"has":      hasCond,
+	"is":       isCond,
+}
+
+var priorityNames = map[string]string{
+	"none": "0", "low": "1", "medium": "2", "high": "3",
+	"нет": "0", "низкий": "1", "средний": "2", "высокий": "3",
+}
+
+// ParseQuery разбирает текст запроса. Ошибка указывает на неверное условие.
+func ParseQuery(source string) (Query, error) {
+	q := Query{source: strings.TrimSpace(source)}
+
+	terms, err := splitQuery(q.source)
+	if err != nil {
+		return Query{}, err
+	}
+	for _, term := range terms {
+		cond, err := parseTerm(term)
+		if err != nil {
+			return Query{}, fmt.Errorf("ошибка в запросе, условие «%s»: %w", term, err)
+		}
+		q.conds = append(q.conds, cond)
+	}
+	return q, nil
+}
+
+// IsEmpty сообщает, что в запросе нет условий.
+func (q Query) IsEmpty() bool {
+	return len(q.conds) == 0
+}
+
+func (q Query) String() string {
+	return q.source
+}
+
+func (q Query) MarshalText() ([]byte, error) {
+	return []byte(q.source), nil
+}
+
+func (q *Query) UnmarshalText(text []byte) error {
+	parsed, err := ParseQuery(string(text))
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+// match сообщает, что задача удовлетворяет всем условиям запроса.
+func (q Query) match(r *taskRow) bool {
+	for _, c := range q.conds {
+		if !c.match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitQuery делит запрос на условия по пробелам вне кавычек.
+func splitQuery(source string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	for _, r := range source {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("ошибка в запросе: не закрыта кавычка")
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+var queryOperators = []string{">=", "<=", "!=", ":", "=", ">", "<"}
+
+func parseTerm(term string) (queryCond, error) {
+	body, negate := strings.CutPrefix(term, "-")
+	if body == "" {
+		return queryCond{}, fmt.Errorf("пустое условие")
+	}
+
+	cond, err := parseCond(body)
+	if err != nil {
+		return queryCond{}, err
+	}
+	if negate {
+		match := cond.match
+		cond.sql = `NOT (` + cond.sql + `)`
+		cond.match = func(r *taskRow) bool { return !match(r) }
+	}
+	return cond, nil
+}
+
+func parseCond(body string) (queryCond, error) {
+	// Поле — латинские буквы и точка до первого оператора; всё остальное —
+	// слово для поиска по тексту.
+	end := strings.IndexFunc(body, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_')
+	})
+	if end <= 0 {
+		return wordCond(unquote(body))
+	}
+	name := strings.ToLower(body[:end])
+	rest := body[end:]
+
+	var op string
+	for _, o := range queryOperators {
+		if strings.HasPrefix(rest, o) {
+			op = o
+			break
+		}
+	}
+	if op == "" {
+		return wordCond(unquote(body))
+	}
+	value := unquote(rest[len(op):])
+	if value == "" {
+		return queryCond{}, fmt.Errorf("не указано значение")
+	}
+
+	if field, ok := strings.CutPrefix(name, "field."); ok {
+		return customFieldCond(field, op, value)
+	}
+	cond, ok := queryFields[name]
+	if !ok {
+		return queryCond{}, fmt.Errorf("неизвестное поле %s", name)
+	}
+	return cond(op, value)
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// compare возвращает SQL-оператор сравнения для оператора запроса.
+func compare(op string) string {
+	switch op {
+	case ":", "=":
+		return "="
+	case "!=":
+		return "!="
+	}
+	return op
+}
+
+// compareResult проверяет результат сравнения c (как у strings.Compare)
+// оператором запроса op.
+func compareResult(op string, c int) bool {
+	switch op {
+	case ":", "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	}
+	return c <= 0
+}
+
+func likePattern(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return `%` + r.Replace(value) + `%`
+}
+
+// foldCase приводит текст к нижнему регистру для сравнения без учёта
+// регистра. В SQL то же делает fold(x): в SQLite это функция sqliteDriver, в
+// PostgreSQL — lower(x).
+func foldCase(s string) string {
+	return strings.ToLower(s)
+}
+
+// likeContains проверяет вхождение подстроки так же, как
+// fold(s) LIKE '%' || fold(value) || '%'.
+func likeContains(s, value string) bool {
+	return strings.Contains(foldCase(s), foldCase(value))
+}
+
+func wordCond(word string) (queryCond, error) {
+	if word == "" {
+		return queryCond{}, fmt.Errorf("пустое условие")
+	}
+	p := likePattern(foldCase(word))
+	return queryCond{
+		sql:  `(fold(s.title) LIKE ? ESCAPE '\' OR fold(COALESCE(s.comment, '')) LIKE ? ESCAPE '\')`,
+		args: []any{p, p},
+		match: func(r *taskRow) bool {
+			return likeContains(r.Title, word) || likeContains(r.Comment, word)
+		},
+	}, nil
+}
+
+func dateCond(column string, field func(r *taskRow) string) func(op, value string) (queryCond, error) {
+	return func(op, value string) (queryCond, error) {
+		switch value {
+		case "today", "сегодня":
+			value = time.Now().Format(date.DATE_FORMAT)
+		case "tomorrow", "завтра":
+			value = time.Now().AddDate(0, 0, 1).Format(date.DATE_FORMAT)
+		}
+		if _, err := time.Parse(date.DATE_FORMAT, value); err != nil {
+			return queryCond{}, fmt.Errorf("неверный формат даты %s, ожидается YYYYMMDD", value)
+		}
+		return queryCond{
+			sql:  `(COALESCE(` + column + `, '') != '' AND ` + column + ` ` + compare(op) + ` ?)`,
+			args: []any{value},
+			match: func(r *taskRow) bool {
+				d := field(r)
+				return d != "" && compareResult(op, strings.Compare(d, value))
+			},
+		}, nil
+	}
+}
+
+// textCond: ":" ищет подстроку, "=" и "!=" сравнивают целиком.
+func textCond(column string, field func(r *taskRow) string) func(op, value string) (queryCond, error) {
+	return func(op, value string) (queryCond, error) {
+		switch op {
+		case ":":
+			return queryCond{
+				sql:   `fold(COALESCE(` + column + `, '')) LIKE ? ESCAPE '\'`,
+				args:  []any{likePattern(foldCase(value))},
+				match: func(r *taskRow) bool { return likeContains(field(r), value) },
+			}, nil
+		case "=", "!=":
+			return queryCond{
+				sql:   `COALESCE(` + column + `, '') ` + op + ` ?`,
+				args:  []any{value},
+				match: func(r *taskRow) bool { return compareResult(op, strings.Compare(field(r), value)) },
+			}, nil
+		}
+		return queryCond{}, fmt.Errorf("оператор %s не применим к тексту", op)
+	}
+}
+
+// repeatCond: "repeat:w" отбирает задачи с правилом этого вида, "=" сравнивает
+// правило целиком.
+func repeatCond(op, value string) (queryCond, error) {
+	switch op {
+	case ":":
+		return queryCond{
+			sql:  `(COALESCE(s.repeat, '') = ? OR fold(COALESCE(s.repeat, '')) LIKE ?)`,
+			args: []any{value, foldCase(value) + ` %`},
+			match: func(r *taskRow) bool {
+				return r.Repeat == value || strings.HasPrefix(foldCase(r.Repeat), foldCase(value)+" ")
+			},
+		}, nil
+	case "=", "!=":
+		return queryCond{
+			sql:   `COALESCE(s.repeat, '') ` + op + ` ?`,
+			args:  []any{value},
+			match: func(r *taskRow) bool { return compareResult(op, strings.Compare(r.Repeat, value)) },
+		}, nil
+	}
+	return queryCond{}, fmt.Errorf("оператор %s не применим к правилу повторения", op)
+}
+
+func tagCond(op, value string) (queryCond, error) {
+	tag := strings.ToLower(strings.TrimPrefix(value, "#"))
+	switch op {
+	case ":", "=":
+		return queryCond{
+			sql:   `EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = s.id AND tt.tag = ?)`,
+			args:  []any{tag},
+			match: func(r *taskRow) bool { return slices.Contains(r.Tags, tag) },
+		}, nil
+	case "!=":
+		return queryCond{
+			sql:   `NOT EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = s.id AND tt.tag = ?)`,
+			args:  []any{tag},
+			match: func(r *taskRow) bool { return !slices.Contains(r.Tags, tag) },
+		}, nil
+	}
+	return queryCond{}, fmt.Errorf("оператор %s не применим к метке", op)
+}
+
+func numberCond(column string, field func(r *taskRow) int, names map[string]string) func(op, value string) (queryCond, error) {
+	return func(op, value string) (queryCond, error) {
+		if name, ok := names[strings.ToLower(value)]; ok {
+			value = name
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return queryCond{}, fmt.Errorf("ожидается число, получено %s", value)
+		}
+		return queryCond{
+			sql:   column + ` ` + compare(op) + ` ?`,
+			args:  []any{n},
+			match: func(r *taskRow) bool { return compareResult(op, field(r)-n) },
+		}, nil
+	}
+}
+
+var hasConds = map[string]queryCond{
+	"comment":     {sql: `COALESCE(s.comment, '') != ''`, match: func(r *taskRow) bool { return r.Comment != "" }},
+	"repeat":      {sql: `COALESCE(s.repeat, '') != ''`, match: func(r *taskRow) bool { return r.Repeat != "" }},
+	"deadline":    {sql: `COALESCE(m.deadline, '') != ''`, match: func(r *taskRow) bool { return r.Deadline != "" }},
+	"estimate":    {sql: `COALESCE(m.estimate, 0) > 0`, match: func(r *taskRow) bool { return r.Estimate > 0 }},
+	"priority":    {sql: `COALESCE(m.priority, 0) > 0`, match: func(r *taskRow) bool { return r.Priority > 0 }},
+	"tags":        {sql: `EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = s.id)`, match: func(r *taskRow) bool { return len(r.Tags) > 0 }},
+	"attachments": {sql: `EXISTS (SELECT 1 FROM attachments a WHERE a.task_id = s.id)`, match: func(r *taskRow) bool { return r.hasAttachments }},
+	"notes":       {sql: `EXISTS (SELECT 1 FROM task_notes n WHERE n.task_id = s.id)`, match: func(r *taskRow) bool { return r.hasNotes }},
+	"fields":      {sql: `EXISTS (SELECT 1 FROM task_fields tf WHERE tf.task_id = s.id)`, match: func(r *taskRow) bool { return len(r.Fields) > 0 }},
+}
+
+func hasCond(op, value string) (queryCond, error) {
+	if op != ":" {
+		return queryCond{}, fmt.Errorf("для has поддерживается только оператор :")
+	}
+	cond, ok := hasConds[strings.ToLower(value)]
+	if !ok {
+		return queryCond{}, fmt.Errorf("неизвестное значение has:%s", value)
+	}
+	return cond, nil
+}
+
+func isCond(op, value string) (queryCond, error) {
+	if op != ":" {
+		return queryCond{}, fmt.Errorf("для is поддерживается только оператор :")
+	}
+	today := time.Now().Format(date.DATE_FORMAT)
+	switch strings.ToLower(value) {
+	case "overdue":
+		return queryCond{
+			sql:   `(s.date < ? OR (COALESCE(m.deadline, '') != '' AND m.deadline < ?))`,
+			args:  []any{today, today},
+			match: func(r *taskRow) bool { return r.Date < today || (r.Deadline != "" && r.Deadline < today) },
+		}, nil
+	case "today":
+		return queryCond{
+			sql:   `s.date = ?`,
+			args:  []any{today},
+			match: func(r *taskRow) bool { return r.Date == today },
+		}, nil
+	case "recurring":
+		return hasConds["repeat"], nil
+	}
+	return queryCond{}, fmt.Errorf("неизвестное значение is:%s", value)
+}
+
+// customFieldCond сравнивает значение пользовательского поля; числа
+// сравниваются как числа, остальное — как строки.
+func customFieldCond(name, op, value string) (queryCond, error) {
+	if name == "" {
+		return queryCond{}, fmt.Errorf("не указано имя пользовательского поля")
+	}
+	var arg any = value
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		arg = n
+	} else if b, err := strconv.ParseBool(value); err == nil {
+		arg = b
+	}
+	return queryCond{
+		sql: `EXISTS (SELECT 1 FROM task_fields tf WHERE tf.task_id = s.id AND tf.name = ? AND json_extract(tf.value, '$') ` +
+			compare(op) + ` json_extract(?, '$'))`,
+		args: []any{name, fieldValue(arg)},
+		match: func(r *taskRow) bool {
+			c, ok := compareValues(r.Fields[name], arg)
+			return ok && compareResult(op, c)
+		},
+	}, nil
+}
+
+// sqlValue приводит значение к виду, в котором его сравнивает SQLite: числом
+// float64, строкой или nil для NULL. Логические значения становятся 1 и 0.
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1.0
+		}
+		return 0.0
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float64, string:
+		return v
+	}
+	return nil
+}
+
+// compareValues сравнивает значения по правилам SQLite: числа меньше строк.
+// Если одно из значений NULL, сравнение не определено и ok равен false.
+func compareValues(a, b any) (c int, ok bool) {
+	a, b = sqlValue(a), sqlValue(b)
+	if a == nil || b == nil {
+		return 0, false
+	}
+	switch a := a.(type) {
+	case float64:
+		if b, isNumber := b.(float64); isNumber {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+		return -1, true
+	case string:
+		if b, isText := b.(string); isText {
+			return strings.Compare(a, b), true
+		}
+		return 1, true
+	}
+	return 0, false
+}
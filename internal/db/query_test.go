@@ -0,0 +1,123 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`title:"незакрытая`, "не закрыта кавычка"},
+		{`-`, "условие «-»: пустое условие"},
+		{`""`, "пустое условие"},
+		{`color:red`, "неизвестное поле color"},
+		{`tag:`, "условие «tag:»: не указано значение"},
+		{`date>2025-01-01`, "неверный формат даты 2025-01-01"},
+		{`deadline<вчера`, "неверный формат даты вчера"},
+		{`title>a`, "оператор > не применим к тексту"},
+		{`repeat<d`, "оператор < не применим к правилу повторения"},
+		{`tag>work`, "оператор > не применим к метке"},
+		{`has=notes`, "для has поддерживается только оператор :"},
+		{`has:color`, "неизвестное значение has:color"},
+		{`is:done`, "неизвестное значение is:done"},
+		{`priority>высший`, "ожидается число, получено высший"},
+		{`field.=1`, "не указано имя пользовательского поля"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := db.ParseQuery(tt.query)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+// testQuery проверяет язык запросов: SQL-условия и проверка задач в Go
+// должны отбирать одни и те же задачи.
+func testQuery(t *testing.T, s db.TaskStore) {
+	insert(t, s,
+		task.Task{Date: days(-1), Title: "Купить молоко", Comment: "магазин: у дома", Tags: []string{"home"},
+			Priority: 1, Estimate: 15},
+		task.Task{Date: today(), Title: "Отчёт за квартал", Repeat: "d 7", Tags: []string{"work"},
+			Priority: 3, Estimate: 120, Deadline: days(1)},
+		task.Task{Date: days(1), Title: "Позвонить", Repeat: "w 1,3", Deadline: days(3)},
+		task.Task{Date: days(4), Title: "Draft: план", Priority: 2},
+	)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		// Слова и кавычки.
+		{`молоко`, []string{"Купить молоко"}},
+		{`"Купить молоко"`, []string{"Купить молоко"}},
+		{`title="Купить молоко"`, []string{"Купить молоко"}},
+		{`title=Купить`, []string{}},
+		{`title:Купить`, []string{"Купить молоко"}},
+		{`comment:"магазин: у"`, []string{"Купить молоко"}},
+		{`"Draft:"`, []string{"Draft: план"}},
+		{`"draft: план"`, []string{"Draft: план"}},
+
+		// Регистр не различается ни у латиницы, ни у кириллицы.
+		{`МОЛОКО`, []string{"Купить молоко"}},
+		{`"купить МОЛОКО"`, []string{"Купить молоко"}},
+		{`title:ОТЧЁТ`, []string{"Отчёт за квартал"}},
+		{`comment:Магазин`, []string{"Купить молоко"}},
+		{`-title:пОЗВОНИТЬ`, []string{"Купить молоко", "Отчёт за квартал", "Draft: план"}},
+		{`"DRAFT: ПЛАН"`, []string{"Draft: план"}},
+		{`repeat:W`, []string{"Позвонить"}},
+
+		// Отрицание.
+		{`-title:Купить`, []string{"Отчёт за квартал", "Позвонить", "Draft: план"}},
+		{`-"Купить молоко"`, []string{"Отчёт за квартал", "Позвонить", "Draft: план"}},
+		{`-has:repeat`, []string{"Купить молоко", "Draft: план"}},
+		{`-is:overdue`, []string{"Отчёт за квартал", "Позвонить", "Draft: план"}},
+		{`tag!=work`, []string{"Купить молоко", "Позвонить", "Draft: план"}},
+		{`-tag:work -tag:home`, []string{"Позвонить", "Draft: план"}},
+		{`-deadline>today`, []string{"Купить молоко", "Draft: план"}},
+		{`-repeat:w`, []string{"Купить молоко", "Отчёт за квартал", "Draft: план"}},
+
+		// Правила повторения.
+		{`repeat="d 7"`, []string{"Отчёт за квартал"}},
+		{`repeat!="d 7"`, []string{"Купить молоко", "Позвонить", "Draft: план"}},
+		{`repeat:d`, []string{"Отчёт за квартал"}},
+
+		// Даты и ключевые слова.
+		{`date=today`, []string{"Отчёт за квартал"}},
+		{`date:сегодня`, []string{"Отчёт за квартал"}},
+		{`date=tomorrow`, []string{"Позвонить"}},
+		{`date=завтра`, []string{"Позвонить"}},
+		{`date<today`, []string{"Купить молоко"}},
+		{`date>tomorrow`, []string{"Draft: план"}},
+		{`date>=today date<=tomorrow`, []string{"Отчёт за квартал", "Позвонить"}},
+		{`date=` + days(4), []string{"Draft: план"}},
+		{`deadline<=tomorrow`, []string{"Отчёт за квартал"}},
+		{`deadline>today`, []string{"Отчёт за квартал", "Позвонить"}},
+		{`is:today`, []string{"Отчёт за квартал"}},
+		{`is:overdue`, []string{"Купить молоко"}},
+
+		// Числа.
+		{`priority=низкий`, []string{"Купить молоко"}},
+		{`priority>=medium`, []string{"Отчёт за квартал", "Draft: план"}},
+		{`priority:none`, []string{"Позвонить"}},
+		{`estimate>60`, []string{"Отчёт за квартал"}},
+		{`estimate<=15`, []string{"Купить молоко", "Позвонить", "Draft: план"}},
+		{`has:tags has:deadline`, []string{"Отчёт за квартал"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := db.ParseQuery(tt.query)
+			require.NoError(t, err)
+			tasks, err := s.GetTasks(db.TaskFilter{Query: &q})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, titles(tasks))
+		})
+	}
+}
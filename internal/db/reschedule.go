@@ -0,0 +1,116 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+const (
+	RescheduleMove   = "move"
+	RescheduleShift  = "shift"
+	RescheduleSpread = "spread"
+)
+
+// RescheduleAction описывает массовый перенос задач: на конкретную дату (move),
+// на N дней (shift) или равномерно на следующие N дней начиная с сегодня (spread).
+type RescheduleAction struct {
+	Type string `json:"type"`
+	Date string `json:"date,omitempty"`
+	Days int    `json:"days,omitempty"`
+}
+
+func (a RescheduleAction) Validate() error {
+	switch a.Type {
+	case RescheduleMove:
+		if _, err := time.Parse(date.DATE_FORMAT, a.Date); err != nil {
+			return fmt.Errorf("неверный формат даты переноса")
+		}
+	case RescheduleShift:
+		if a.Days == 0 {
+			return fmt.Errorf("не указан сдвиг в днях")
+		}
+	case RescheduleSpread:
+		if a.Days < 1 {
+			return fmt.Errorf("не указано число дней для распределения")
+		}
+	default:
+		return fmt.Errorf("неподдерживаемое действие %q", a.Type)
+	}
+	return nil
+}
+
+// RescheduleResult — итог массового переноса. Крайний срок при массовом
+// переносе не меняется, поэтому задачи, которые ушли бы за него, остаются на
+// месте и перечислены в Skipped с датой, на которую их не удалось перенести.
+type RescheduleResult struct {
+	Moved   []DateChange `json:"moved"`
+	Skipped []DateChange `json:"skipped"`
+}
+
+// shiftsDeadline сообщает, сдвигается ли крайний срок вместе с датой при
+// переносе action: при выполнении повторяющейся задачи и переносе просроченной
+// задачи срок относится к следующему повторению, а массовый перенос не должен
+// незаметно отодвигать срок.
+func shiftsDeadline(action string) bool {
+	return action != RevisionReschedule
+}
+
+// pastDeadline сообщает, что перенос задачи на newDate уводит её за крайний
+// срок; такую задачу Reschedule пропускает.
+func pastDeadline(t task.Task, newDate string) bool {
+	return t.Deadline != "" && newDate > t.Deadline
+}
+
+// Reschedule переносит все задачи, подходящие под фильтр, в одной транзакции.
+func (s *Storage) Reschedule(f TaskFilter, a RescheduleAction, now time.Time) (RescheduleResult, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return RescheduleResult{}, err
+	}
+	defer tx.Rollback()
+
+	where, args := f.where(now)
+	tasks, err := queryTasks(tx, selectTaskQuery+where+` ORDER BY s.date ASC, s.id ASC`, args...)
+	if err != nil {
+		return RescheduleResult{}, err
+	}
+
+	today := now.Format(date.DATE_FORMAT)
+	result := RescheduleResult{Moved: make([]DateChange, 0, len(tasks)), Skipped: make([]DateChange, 0)}
+	for i, t := range tasks {
+		var newDate string
+		switch a.Type {
+		case RescheduleMove:
+			newDate = a.Date
+		case RescheduleShift:
+			newDate, err = date.AddDays(t.Date, a.Days)
+			if err != nil {
+				return RescheduleResult{}, err
+			}
+		case RescheduleSpread:
+			newDate, err = date.AddDays(today, i*a.Days/len(tasks))
+			if err != nil {
+				return RescheduleResult{}, err
+			}
+		}
+
+		if newDate == t.Date {
+			continue
+		}
+		change := DateChange{ID: t.ID, Title: t.Title, OldDate: t.Date, NewDate: newDate}
+		if pastDeadline(t, newDate) {
+			change.Deadline = t.Deadline
+			result.Skipped = append(result.Skipped, change)
+			continue
+		}
+		if err := s.moveTask(tx, t, newDate, 0, RevisionReschedule); err != nil {
+			return RescheduleResult{}, err
+		}
+		result.Moved = append(result.Moved, change)
+	}
+
+	return result, tx.Commit()
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+const (
+	RevisionCreate     = "create"
+	RevisionUpdate     = "update"
+	RevisionDone       = "done"
+	RevisionReschedule = "reschedule"
+	RevisionRollover   = "rollover"
+	RevisionDelete     = "delete"
+	RevisionRevert     = "revert"
+)
+
+// RolloverAuthor — автор изменений, сделанных ночным переносом задач.
+const RolloverAuthor = "rollover"
+
+// Revision — одно изменение задачи. Task хранит состояние задачи после изменения,
+// а для удаления — последнее состояние перед ним.
+type Revision struct {
+	ID        int64         `json:"id"`
+	TaskID    string        `json:"task_id"`
+	Author    string        `json:"author"`
+	Action    string        `json:"action"`
+	CreatedAt time.Time     `json:"created_at"`
+	Changes   []task.Change `json:"changes"`
+	Task      task.Task     `json:"-"`
+}
+
+func (s *Storage) recordRevision(tx *sqlTx, taskID string, before, after *task.Task, action string) error {
+	var old, new task.Task
+	if before != nil {
+		old = *before
+	}
+	snapshot := old
+	if after != nil {
+		new = *after
+		snapshot = new
+	}
+	snapshot.ID = taskID
+	snapshot.Overdue = false
+
+	changes := task.Diff(old, new)
+	if len(changes) == 0 && action == RevisionUpdate {
+		return nil
+	}
+	if err := s.recordUndo(tx, taskID, before, after); err != nil {
+		return err
+	}
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO task_revisions (task_id, author, action, created_at, changes, snapshot) VALUES (?, ?, ?, ?, ?, ?)`,
+		taskID, s.author, action, time.Now().UTC().Format(time.RFC3339Nano), string(changesJSON), string(snapshotJSON))
+	return err
+}
+
+// GetRevisions возвращает историю изменений задачи, начиная с последнего.
+func (s *Storage) GetRevisions(taskID string) ([]Revision, error) {
+	rows, err := s.db.Query(`SELECT id, task_id, author, action, created_at, changes, snapshot
+FROM task_revisions WHERE task_id = ? ORDER BY id DESC`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]Revision, 0)
+	for rows.Next() {
+		r, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// lastSnapshot возвращает состояние задачи из её последней ревизии; для
+// удалённой задачи это состояние перед удалением.
+func (s *Storage) lastSnapshot(taskID string) (task.Task, error) {
+	r, err := scanRevision(s.db.QueryRow(`SELECT id, task_id, author, action, created_at, changes, snapshot
+FROM task_revisions WHERE task_id = ? ORDER BY id DESC LIMIT 1`, taskID))
+	return r.Task, err
+}
+
+// RevertTask возвращает задачу к состоянию из ревизии revisionID. Удалённая
+// задача создаётся заново с прежним идентификатором.
+func (s *Storage) RevertTask(revisionID string) (task.Task, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return task.Task{}, err
+	}
+	defer tx.Rollback()
+
+	r, err := scanRevision(tx.QueryRow(`SELECT id, task_id, author, action, created_at, changes, snapshot
+FROM task_revisions WHERE id = ?`, revisionID))
+	if err != nil {
+		return task.Task{}, err
+	}
+
+	var before *task.Task
+	if current, err := getTask(tx, r.TaskID); err == nil {
+		before = &current
+	} else if err != sql.ErrNoRows {
+		return task.Task{}, err
+	}
+
+	restored := r.Task
+	restored.Version = 0
+	if err := restoreTask(tx, restored); err != nil {
+		return task.Task{}, err
+	}
+	if err := s.recordRevision(tx, r.TaskID, before, &r.Task, RevisionRevert); err != nil {
+		return task.Task{}, err
+	}
+
+	return r.Task, tx.Commit()
+}
+
+func scanRevision(row rowScanner) (Revision, error) {
+	var r Revision
+	var created, changes, snapshot string
+	if err := row.Scan(&r.ID, &r.TaskID, &r.Author, &r.Action, &created, &changes, &snapshot); err != nil {
+		return r, err
+	}
+
+	var err error
+	if r.CreatedAt, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal([]byte(changes), &r.Changes); err != nil {
+		return r, err
+	}
+	err = json.Unmarshal([]byte(snapshot), &r.Task)
+	return r, err
+}
@@ -0,0 +1,162 @@
+package db
+
+import (
+	"database/sql"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Найденные слова во фрагменте Snippet обрамляются тегами <mark>, остальной
+// текст экранируется, поэтому фрагмент можно вставлять в страницу как HTML.
+const (
+	markOpen  = "\x02"
+	markClose = "\x03"
+)
+
+// dropSearchTriggers удаляет триггеры индекса поиска, которые создавали на
+// scheduler прежние сборки с FTS5: без модуля fts5 они ломают запись в
+// таблицу.
+func dropSearchTriggers(db *sql.DB) error {
+	for _, name := range []string{"scheduler_search_insert", "scheduler_search_delete", "scheduler_search_update"} {
+		if _, err := db.Exec(`DROP TRIGGER IF EXISTS ` + name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// searchTerms разбивает строку поиска на слова в нижнем регистре.
+func searchTerms(text string) []string {
+	return strings.FieldsFunc(foldText(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// foldText заменяет «ё» на «е»: пользователи пишут их вперемешку.
+func foldText(s string) string {
+	return strings.NewReplacer("ё", "е", "Ё", "Е").Replace(s)
+}
+
+func foldRune(r rune) rune {
+	r = unicode.ToLower(r)
+	if r == 'ё' {
+		return 'е'
+	}
+	return r
+}
+
+// scanSearch просматривает задачи, отобранные фильтром f, по порядку дат и
+// сравнивает их текст со словами terms в Go: LIKE в SQLite не различает
+// регистр только у латиницы. Так ищут сборка без FTS5 и PostgreSQL.
+func (s *Storage) scanSearch(terms []string, f TaskFilter, p Page) (TaskPage, error) {
+	where, args := f.where(time.Now())
+	rows, err := s.db.Query(selectTaskQuery+where+` ORDER BY s.date ASC, s.id ASC`, args...)
+	if err != nil {
+		return TaskPage{}, err
+	}
+	defer rows.Close()
+
+	offset := 0
+	if p.Cursor != nil {
+		offset = p.Cursor.Offset
+	}
+	limit := p.limit()
+
+	now := time.Now()
+	tasks := make([]task.Task, 0)
+	found := 0
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		if !containsTerms(t, terms) {
+			continue
+		}
+
+		found++
+		if found <= offset || found > offset+limit {
+			// Дальше задачи нужны только для подсчёта.
+			if found > offset+limit && !p.Total {
+				break
+			}
+			continue
+		}
+		t.Snippet = snippet(t, terms)
+		t.SetOverdue(now)
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return TaskPage{}, err
+	}
+
+	page := offsetPage(tasks, offset, found > offset+limit, limit)
+	if p.Total {
+		page.Total = found
+	}
+	return page, nil
+}
+
+// containsTerms сообщает, что в заголовке или комментарии задачи есть все terms.
+func containsTerms(t task.Task, terms []string) bool {
+	text := foldText(strings.ToLower(t.Title + " " + t.Comment))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// snippet возвращает заголовок задачи, а если слова найдены только в
+// комментарии — комментарий, с выделенными вхождениями terms.
+func snippet(t task.Task, terms []string) string {
+	s := highlight(t.Title, terms)
+	if !strings.Contains(s, markOpen) && t.Comment != "" {
+		if c := highlight(t.Comment, terms); strings.Contains(c, markOpen) {
+			s = c
+		}
+	}
+
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, markOpen, "<mark>")
+	return strings.ReplaceAll(s, markClose, "</mark>")
+}
+
+// highlight обрамляет служебными метками вхождения terms в text без учёта
+// регистра и различия «ё» и «е».
+func highlight(text string, terms []string) string {
+	runes := []rune(text)
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = foldRune(r)
+	}
+
+	marked := make([]bool, len(runes))
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(folded); i++ {
+			if string(folded[i:i+len(t)]) == term {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+
+	var b strings.Builder
+	for i, r := range runes {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(markOpen)
+		}
+		b.WriteRune(r)
+		if marked[i] && (i == len(runes)-1 || !marked[i+1]) {
+			b.WriteString(markClose)
+		}
+	}
+	return b.String()
+}
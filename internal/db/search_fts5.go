@@ -0,0 +1,127 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Индекс task_search не хранит текст задач, а обновляется в writeTask и
+// removeTask. Триггеров на scheduler нет: с ними в таблицу не смог бы писать
+// клиент SQLite без FTS5, например тесты из tests/. Записи в обход сервера
+// попадают в индекс при следующем запуске, когда он перестраивается.
+// В индекс попадает текст с «ё», заменённой на «е» (unicode61 не считает её
+// диакритикой), а префиксный индекс ускоряет поиск по началу слова, который
+// заменяет русскую морфологию.
+const createSearchQuery = `
+CREATE VIRTUAL TABLE IF NOT EXISTS task_search USING fts5(
+	title, comment,
+	content = '', contentless_delete = 1,
+	tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3'
+)`
+
+const rebuildSearchQuery = `DELETE FROM task_search;
+INSERT INTO task_search (rowid, title, comment)
+SELECT s.id, replace(replace(s.title, 'ё', 'е'), 'Ё', 'Е'), replace(replace(s.comment, 'ё', 'е'), 'Ё', 'Е') FROM scheduler s`
+
+func initSearch(db *sql.DB) error {
+	if err := dropSearchTriggers(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(createSearchQuery); err != nil {
+		return err
+	}
+	_, err := db.Exec(rebuildSearchQuery)
+	return err
+}
+
+// indexTask заменяет запись задачи в индексе поиска.
+func indexTask(tx *sqlTx, t task.Task) error {
+	if tx.dialect != sqliteDialect {
+		return nil
+	}
+	if err := unindexTask(tx, t.ID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`INSERT INTO task_search (rowid, title, comment) VALUES (?, ?, ?)`,
+		t.ID, foldText(t.Title), foldText(t.Comment))
+	return err
+}
+
+func unindexTask(tx *sqlTx, id string) error {
+	if tx.dialect != sqliteDialect {
+		return nil
+	}
+	_, err := tx.Exec(`DELETE FROM task_search WHERE rowid = ?`, id)
+	return err
+}
+
+// SearchTasks ищет задачи, в заголовке или комментарии которых есть слова,
+// начинающиеся с каждого слова text, и отбирает их фильтром f. Задачи
+// упорядочены по релевантности, совпадения в заголовке весят больше, а
+// страницы отсчитываются смещением.
+func (s *Storage) SearchTasks(text string, f TaskFilter, p Page) (TaskPage, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return s.GetTaskPage(f, p)
+	}
+	if s.db.dialect != sqliteDialect {
+		return s.scanSearch(terms, f, p)
+	}
+	match := make([]string, len(terms))
+	for i, term := range terms {
+		match[i] = `"` + term + `"*`
+	}
+
+	conds, args := f.conditions(time.Now())
+	args = append([]any{strings.Join(match, " ")}, args...)
+	from := `
+FROM task_search JOIN scheduler s ON s.id = task_search.rowid LEFT JOIN task_meta m ON m.task_id = s.id
+WHERE task_search MATCH ?`
+	for _, cond := range conds {
+		from += ` AND ` + cond
+	}
+
+	offset := 0
+	if p.Cursor != nil {
+		offset = p.Cursor.Offset
+	}
+	limit := p.limit()
+	rows, err := s.db.Query(`SELECT `+taskColumns+from+` ORDER BY bm25(task_search, 10.0, 1.0), s.date ASC LIMIT ? OFFSET ?`,
+		append(args, limit+1, offset)...)
+	if err != nil {
+		return TaskPage{}, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	tasks := make([]task.Task, 0)
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		t.Snippet = snippet(t, terms)
+		t.SetOverdue(now)
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return TaskPage{}, err
+	}
+
+	more := len(tasks) > limit
+	if more {
+		tasks = tasks[:limit]
+	}
+	page := offsetPage(tasks, offset, more, limit)
+	if p.Total {
+		if err := s.db.QueryRow(`SELECT count(*)`+from, args...).Scan(&page.Total); err != nil {
+			return TaskPage{}, err
+		}
+	}
+	return page, nil
+}
@@ -0,0 +1,29 @@
+//go:build !sqlite_fts5
+
+package db
+
+import (
+	"database/sql"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Без FTS5 поиск просматривает задачи по порядку дат (см. scanSearch).
+func initSearch(db *sql.DB) error {
+	return dropSearchTriggers(db)
+}
+
+func indexTask(tx *sqlTx, t task.Task) error { return nil }
+
+func unindexTask(tx *sqlTx, id string) error { return nil }
+
+// SearchTasks ищет задачи, в заголовке или комментарии которых есть все слова
+// text, и отбирает их фильтром f. Задачи упорядочены по дате, а страницы
+// отсчитываются смещением.
+func (s *Storage) SearchTasks(text string, f TaskFilter, p Page) (TaskPage, error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return s.GetTaskPage(f, p)
+	}
+	return s.scanSearch(terms, f, p)
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// SeedAuthor — автор задач, созданных из файла начальных данных.
+const SeedAuthor = "seed"
+
+// Seed заполняет пустое хранилище задачами из JSON-файла path — массива задач
+// в том же виде, что принимает POST /api/task. Если в хранилище уже есть
+// задачи, файл не читается. Возвращает число созданных задач.
+func Seed(store TaskStore, path string) (int, error) {
+	existing, err := store.GetTasks(TaskFilter{})
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 {
+		return 0, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var tasks []task.Task
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		return 0, fmt.Errorf("ошибка разбора файла %s: %w", path, err)
+	}
+	for i := range tasks {
+		tasks[i].ID = ""
+		if err := tasks[i].ValidateTask(); err != nil {
+			return 0, fmt.Errorf("задача %d: %w", i+1, err)
+		}
+	}
+
+	ids, err := store.As(SeedAuthor).InsertTasks(tasks)
+	return len(ids), err
+}
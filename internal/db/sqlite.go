@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver — драйвер SQLite с функцией fold(text), которая приводит текст
+// к нижнему регистру так же, как foldCase. Встроенные lower и LIKE в SQLite
+// знают регистр только у латиницы, а условия запросов должны одинаково
+// находить «Отчёт» и «ОТЧЁТ» во всех хранилищах.
+const sqliteDriver = "sqlite3_scheduler"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("fold", foldCase, true)
+		},
+	})
+}
+
+// sqliteParams — настройки соединений SQLite:
+//   - журнал WAL: чтение не ждёт записи, а запись — чтения;
+//   - busy_timeout: занятая база сначала ждёт внутри SQLite, а не сразу
+//     возвращает SQLITE_BUSY;
+//   - _txlock=immediate: транзакция сразу берёт блокировку записи. Иначе две
+//     транзакции, которые сначала читают, а потом пишут, мешают друг другу, и
+//     одна из них получает SQLITE_BUSY без ожидания;
+//   - synchronous=NORMAL: в режиме WAL база не повреждается при сбое, а
+//     последние транзакции теряются только при отключении питания.
+const sqliteParams = "?_journal_mode=WAL&_busy_timeout=1000&_txlock=immediate&_synchronous=NORMAL"
+
+// sqliteIdleConns — сколько соединений пул держит открытыми между запросами.
+// Подготовленные запросы живут в соединении, поэтому закрытое соединение
+// заставило бы готовить их заново. Число открытых соединений не ограничено:
+// транзакции ждут блокировку записи внутри SQLite, занимая соединение, и
+// ограничение пула заставило бы чтение ждать их в очереди.
+const sqliteIdleConns = 16
+
+// Если база занята дольше busy_timeout, операция повторяется до busyRetries
+// раз с паузой, которая начинается с busyBackoff и растёт вдвое.
+const (
+	busyRetries = 5
+	busyBackoff = 20 * time.Millisecond
+)
+
+// configureSQLite настраивает пул соединений базы SQLite.
+func configureSQLite(db *sql.DB) {
+	db.SetMaxIdleConns(sqliteIdleConns)
+	db.SetConnMaxIdleTime(5 * time.Minute)
+}
+
+// retryBusy выполняет op и повторяет её, пока база занята, с растущей
+// случайной паузой, чтобы ждущие не просыпались одновременно.
+func retryBusy(ctx context.Context, d *dialect, op func() error) error {
+	err := op()
+	for attempt := 0; attempt < busyRetries && d.busy != nil && d.busy(err); attempt++ {
+		pause := busyBackoff << attempt
+		pause += rand.N(pause)
+		select {
+		case <-ctx.Done():
+			return contextError(ctx, err)
+		case <-time.After(pause):
+		}
+		err = op()
+	}
+	return err
+}
+
+// stmtCacheSize ограничивает число подготовленных запросов: запросы списка
+// задач собираются из фильтров, и разных текстов у них может быть много.
+const stmtCacheSize = 256
+
+// stmtCache хранит подготовленные запросы Storage. Запрос готовится один раз,
+// а на других соединениях пула database/sql готовит его сам при первом
+// выполнении.
+type stmtCache struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// get возвращает подготовленный запрос query. Если кэша нет или он заполнен,
+// возвращается nil, и запрос выполняется без подготовки.
+func (c *stmtCache) get(ctx context.Context, query string) (*sql.Stmt, error) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	stmt, ok := c.stmts[query]
+	full := len(c.stmts) >= stmtCacheSize
+	c.mu.Unlock()
+	if ok || full {
+		return stmt, nil
+	}
+
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Тот же запрос мог подготовить параллельный вызов.
+	if cached, ok := c.stmts[query]; ok {
+		stmt.Close()
+		return cached, nil
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
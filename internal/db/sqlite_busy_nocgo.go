@@ -0,0 +1,8 @@
+//go:build !cgo
+
+package db
+
+// Без cgo драйвер SQLite не работает, и занятой базы не бывает.
+func isSQLiteBusy(err error) bool {
+	return false
+}
@@ -0,0 +1,5 @@
+//go:build cgo
+
+package db_test
+
+const sqliteAvailable = true
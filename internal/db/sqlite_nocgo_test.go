@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package db_test
+
+// Без cgo драйвер SQLite не работает, и тесты SQLite пропускаются.
+const sqliteAvailable = false
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+	"github.com/imbalaancing/go_final_project/internal/templates"
+)
+
+// TaskStore — хранилище задач и связанных с ними данных. Его реализуют Storage
+// поверх SQLite или PostgreSQL и MemoryStorage, хранящее всё в памяти процесса
+// и, если оно открыто через OpenJournal, в журнале на диске.
+//
+// Отсутствующая запись во всех реализациях обозначается sql.ErrNoRows, а
+// методы Update* и Delete* возвращают число изменённых записей.
+type TaskStore interface {
+	// As возвращает хранилище, которое записывает изменения задач от имени author.
+	As(author string) TaskStore
+	// Recording возвращает хранилище, которое дописывает в steps обратные
+	// операции для всех изменений задач.
+	Recording(steps *[]UndoStep) TaskStore
+	// WithContext возвращает хранилище, запросы которого отменяются вместе с
+	// ctx, а каждый запрос к базе ограничен QueryTimeout. Ошибка отменённого
+	// запроса содержит context.Canceled или context.DeadlineExceeded.
+	WithContext(ctx context.Context) TaskStore
+
+	InsertTask(t task.Task) (int64, error)
+	InsertTasks(tasks []task.Task) ([]string, error)
+	GetTask(id string) (task.Task, error)
+	GetTasks(f TaskFilter) ([]task.Task, error)
+	GetTaskPage(f TaskFilter, p Page) (TaskPage, error)
+	SearchTasks(text string, f TaskFilter, p Page) (TaskPage, error)
+	// UpdateTask сохраняет задачу, если её версия не изменилась с t.Version,
+	// иначе возвращает ErrVersionConflict. При t.Version == 0 задача
+	// сохраняется без проверки. Каждое изменение задачи увеличивает её версию.
+	UpdateTask(t task.Task) (int64, error)
+	// MarkTaskDone и DeleteTask так же проверяют версию задачи, если version
+	// не 0.
+	MarkTaskDone(id string, version int64) error
+	// DeleteTask удаляет задачу, но её заметки, вложения, учтённое время и
+	// фокус-сессии остаются: отмена удаления и RevertTask возвращают задачу
+	// вместе с ними. Заметки и вложения удалённой задачи скрыты, пока
+	// PurgeDeleted не удалит их совсем.
+	DeleteTask(id string, version int64) (int64, error)
+	PurgeDeleted(before time.Time) (int, error)
+	RolloverOverdue(now time.Time) ([]DateChange, error)
+	Reschedule(f TaskFilter, a RescheduleAction, now time.Time) (RescheduleResult, error)
+
+	GetRevisions(taskID string) ([]Revision, error)
+	RevertTask(revisionID string) (task.Task, error)
+	Undo(steps []UndoStep) error
+
+	StartTimer(id string, now time.Time) error
+	StopTimer(id string, now time.Time) error
+	TimeReport(groupBy, from, to string, now time.Time) ([]TimeReportRow, error)
+
+	InsertPomodoro(p PomodoroSession) (int64, error)
+	FinishPomodoro(id int64, endedAt time.Time, completed bool) error
+	ActivePomodoro() (PomodoroSession, error)
+	FocusSummary(day time.Time) ([]FocusSummaryRow, error)
+
+	SetAttachmentsDir(dir string) error
+	InsertAttachment(a Attachment, content []byte) (int64, error)
+	GetAttachments(taskID string) ([]Attachment, error)
+	GetAttachment(id string) (Attachment, []byte, error)
+	DeleteAttachment(id string) (int64, error)
+
+	InsertNote(taskID, body string) (int64, error)
+	GetNotes(taskID string) ([]Note, error)
+	GetNote(id string) (Note, error)
+	UpdateNote(id, body string) (int64, error)
+	DeleteNote(id string) (int64, error)
+
+	GetFieldDefs() ([]task.FieldDef, error)
+	InsertFieldDef(d task.FieldDef) error
+	UpdateFieldDef(d task.FieldDef) (int64, error)
+	DeleteFieldDef(name string) (int64, error)
+
+	InsertTemplate(t templates.Template) (int64, error)
+	GetTemplates() ([]templates.Template, error)
+	GetTemplate(id string) (templates.Template, error)
+	UpdateTemplate(t templates.Template) (int64, error)
+	DeleteTemplate(id string) (int64, error)
+
+	InsertView(v View) (int64, error)
+	GetViews() ([]View, error)
+	GetView(id string) (View, error)
+	UpdateView(v View) (int64, error)
+	DeleteView(id string) (int64, error)
+}
+
+var (
+	_ TaskStore = (*Storage)(nil)
+	_ TaskStore = (*MemoryStorage)(nil)
+)
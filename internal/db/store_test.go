@@ -0,0 +1,1103 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/db"
+	"github.com/imbalaancing/go_final_project/internal/task"
+	"github.com/imbalaancing/go_final_project/internal/templates"
+)
+
+// Все реализации TaskStore проходят один набор тестов, поэтому ведут себя
+// одинаково для обработчиков API.
+
+// skipWithoutSQLite пропускает тест, если сервер собран без cgo и SQLite
+// недоступна.
+func skipWithoutSQLite(t testing.TB) {
+	if !sqliteAvailable {
+		t.Skip("SQLite недоступна без cgo")
+	}
+}
+
+func newSQLiteStore(t *testing.T) db.TaskStore {
+	database, err := db.InitDB(filepath.Join(t.TempDir(), "scheduler.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	return db.NewTaskStorage(database)
+}
+
+func newMemoryStore(t *testing.T) db.TaskStore {
+	return db.NewMemoryStorage()
+}
+
+func newJournalStore(t *testing.T) db.TaskStore {
+	s, err := db.OpenJournal(filepath.Join(t.TempDir(), "scheduler"+db.JournalExt))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// postgresTestDSN создаёт для теста отдельную схему в базе
+// TODO_TEST_DATABASE_URL, удаляет её после теста и возвращает строку
+// подключения, в которой эта схема выбрана по умолчанию.
+func postgresTestDSN(t *testing.T) string {
+	dsn := os.Getenv("TODO_TEST_DATABASE_URL")
+	admin, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func newPostgresStore(t *testing.T) db.TaskStore {
+	database, err := db.InitPostgres(postgresTestDSN(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	return db.NewPostgresStorage(database)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	skipWithoutSQLite(t)
+	testTaskStore(t, newSQLiteStore)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testTaskStore(t, newMemoryStore)
+}
+
+func TestJournalStore(t *testing.T) {
+	testTaskStore(t, newJournalStore)
+}
+
+// TestSQLiteContext проверяет, что запросы Storage отменяются вместе с
+// контекстом и ошибка сообщает причину отмены.
+func TestSQLiteContext(t *testing.T) {
+	skipWithoutSQLite(t)
+	testContext(t, newSQLiteStore(t))
+}
+
+func TestPostgresContext(t *testing.T) {
+	if os.Getenv("TODO_TEST_DATABASE_URL") == "" {
+		t.Skip("TODO_TEST_DATABASE_URL не задана")
+	}
+	testContext(t, newPostgresStore(t))
+}
+
+func testContext(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s, task.Task{Date: today(), Title: "Задача"})
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.WithContext(canceled).GetTask(ids[0])
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = s.WithContext(canceled).InsertTask(task.Task{Date: today(), Title: "Отменена"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = s.WithContext(expired).GetTasks(db.TaskFilter{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	tasks, err := s.WithContext(context.Background()).GetTasks(db.TaskFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Задача"}, titles(tasks))
+}
+
+// TestSQLiteConcurrentWrites проверяет, что одновременные транзакции,
+// которые читают и затем пишут, ждут друг друга, а не получают
+// «database is locked».
+func TestSQLiteConcurrentWrites(t *testing.T) {
+	skipWithoutSQLite(t)
+	s := newSQLiteStore(t)
+	ids := insert(t, s, task.Task{Date: today(), Title: "Задача"})
+
+	const workers, updates = 8, 20
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*updates)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < updates; i++ {
+				got, err := s.GetTask(ids[0])
+				if err == nil {
+					got.Version = 0
+					got.Title = fmt.Sprintf("Правка %d.%d", w, i)
+					_, err = s.UpdateTask(got)
+				}
+				if err == nil {
+					_, err = s.InsertTask(task.Task{Date: today(), Title: "Новая"})
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	revisions, err := s.GetRevisions(ids[0])
+	require.NoError(t, err)
+	assert.Len(t, revisions, workers*updates+1)
+}
+
+// TestJournalReopen проверяет, что журнал восстанавливает все данные после
+// перезапуска, в том числе поля, скрытые от JSON, и отбрасывает недописанную
+// при сбое запись.
+func TestJournalReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scheduler"+db.JournalExt)
+	s, err := db.OpenJournal(path)
+	require.NoError(t, err)
+
+	ids := insert(t, s,
+		task.Task{Date: days(2), Title: "Позже", Tags: []string{"work"}, Fields: map[string]any{"points": 2.0}},
+		task.Task{Date: days(1), Title: "Раньше", Repeat: "d 1"},
+	)
+	_, err = s.DeleteTask(ids[1], 0)
+	require.NoError(t, err)
+	_, err = s.InsertTask(task.Task{Date: today(), Title: "Сегодня"})
+	require.NoError(t, err)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	pomodoro, err := s.InsertPomodoro(db.PomodoroSession{TaskID: ids[0], Kind: db.PomodoroFocus, StartedAt: start, EndsAt: start.Add(25 * time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, s.FinishPomodoro(pomodoro, start.Add(25*time.Minute), true))
+	require.NoError(t, s.SetAttachmentsDir(t.TempDir()))
+	attachment, err := s.InsertAttachment(db.Attachment{TaskID: ids[0], Name: "a.txt"}, []byte("hello"))
+	require.NoError(t, err)
+
+	before, err := s.GetTasks(db.TaskFilter{})
+	require.NoError(t, err)
+	revisions, err := s.GetRevisions(ids[0])
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// Недописанная последняя строка журнала отбрасывается.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"ops":[{"t":"tasks","k":99`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s, err = db.OpenJournal(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	after, err := s.GetTasks(db.TaskFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+	assert.Equal(t, []string{"Сегодня", "Позже"}, titles(after))
+
+	got, err := s.GetRevisions(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, revisions, got)
+	_, err = s.RevertTask(strconv.FormatInt(got[0].ID, 10))
+	require.NoError(t, err)
+
+	summary, err := s.FocusSummary(start)
+	require.NoError(t, err)
+	assert.Equal(t, []db.FocusSummaryRow{{TaskID: ids[0], Title: "Позже", Sessions: 1, Minutes: 25}}, summary)
+
+	_, data, err := s.GetAttachment(strconv.FormatInt(attachment, 10))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), data)
+
+	// Идентификаторы удалённых задач не выдаются повторно.
+	id, err := s.InsertTask(task.Task{Date: today(), Title: "Новая"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, id)
+
+	// Версия удалённой задачи тоже сохраняется в журнале.
+	deleted, err := s.GetRevisions(ids[1])
+	require.NoError(t, err)
+	_, err = s.RevertTask(strconv.FormatInt(deleted[1].ID, 10))
+	require.NoError(t, err)
+	restored, err := s.GetTask(ids[1])
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, restored.Version)
+}
+
+func TestPostgresStore(t *testing.T) {
+	if os.Getenv("TODO_TEST_DATABASE_URL") == "" {
+		t.Skip("TODO_TEST_DATABASE_URL не задана")
+	}
+	testTaskStore(t, newPostgresStore)
+}
+
+func testTaskStore(t *testing.T, newStore func(t *testing.T) db.TaskStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s db.TaskStore)
+	}{
+		{"Tasks", testTasks},
+		{"Filters", testFilters},
+		{"Query", testQuery},
+		{"Pages", testPages},
+		{"Done", testDone},
+		{"Versions", testVersions},
+		{"Revisions", testRevisions},
+		{"UndoConflict", testUndoConflict},
+		{"DeletedData", testDeletedData},
+		{"Reschedule", testReschedule},
+		{"Timers", testTimers},
+		{"Pomodoro", testPomodoro},
+		{"Attachments", testAttachments},
+		{"Notes", testNotes},
+		{"Fields", testFields},
+		{"Templates", testTemplates},
+		{"Views", testViews},
+		{"Search", testSearch},
+		{"Seed", testSeed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func today() string {
+	return time.Now().Format(date.DATE_FORMAT)
+}
+
+func days(n int) string {
+	return time.Now().AddDate(0, 0, n).Format(date.DATE_FORMAT)
+}
+
+func insert(t *testing.T, s db.TaskStore, tasks ...task.Task) []string {
+	ids, err := s.InsertTasks(tasks)
+	require.NoError(t, err)
+	return ids
+}
+
+func titles(tasks []task.Task) []string {
+	list := make([]string, len(tasks))
+	for i, t := range tasks {
+		list[i] = t.Title
+	}
+	return list
+}
+
+func testTasks(t *testing.T, s db.TaskStore) {
+	id, err := s.InsertTask(task.Task{
+		Date: days(1), Title: "Отчёт", Comment: "квартальный", Repeat: "d 7", Deadline: days(3),
+		Tags: []string{"work", "docs"}, Estimate: 30, Priority: task.PriorityHigh,
+		Fields: map[string]any{"points": 3.0, "client": "acme"},
+	})
+	require.NoError(t, err)
+	taskID := strconv.FormatInt(id, 10)
+
+	got, err := s.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, task.Task{
+		ID: taskID, Date: days(1), Title: "Отчёт", Comment: "квартальный", Repeat: "d 7", Deadline: days(3),
+		Tags: []string{"docs", "work"}, Estimate: 30, Priority: task.PriorityHigh,
+		Fields: map[string]any{"points": 3.0, "client": "acme"}, Version: 1,
+	}, got)
+
+	got.Title = "Годовой отчёт"
+	got.Tags = nil
+	got.Fields = nil
+	n, err := s.UpdateTask(got)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	updated, err := s.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "Годовой отчёт", updated.Title)
+	assert.Nil(t, updated.Tags)
+	assert.Nil(t, updated.Fields)
+
+	n, err = s.UpdateTask(task.Task{ID: "999", Date: today(), Title: "нет"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	n, err = s.DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = s.GetTask(taskID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	n, err = s.DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, n)
+
+	past, err := s.InsertTask(task.Task{Date: days(-1), Title: "Вчера"})
+	require.NoError(t, err)
+	overdue, err := s.GetTask(strconv.FormatInt(past, 10))
+	require.NoError(t, err)
+	assert.True(t, overdue.Overdue)
+}
+
+func testFilters(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s,
+		task.Task{Date: days(-2), Title: "Просрочена", Tags: []string{"work"}, Priority: 3},
+		task.Task{Date: days(1), Title: "Завтра", Comment: "купить хлеб", Repeat: "w 1", Tags: []string{"home"}},
+		task.Task{Date: days(5), Title: "Позже", Deadline: days(6), Tags: []string{"work"}, Priority: 1,
+			Fields: map[string]any{"points": 5.0}},
+		task.Task{Date: days(10), Title: "Draft plan", Fields: map[string]any{"points": 8.0, "done": true}},
+	)
+	_, err := s.InsertNote(ids[3], "заметка")
+	require.NoError(t, err)
+
+	query := func(q string) *db.Query {
+		parsed, err := db.ParseQuery(q)
+		require.NoError(t, err)
+		return &parsed
+	}
+
+	tests := []struct {
+		name   string
+		filter db.TaskFilter
+		want   []string
+	}{
+		{"empty", db.TaskFilter{}, []string{"Просрочена", "Завтра", "Позже", "Draft plan"}},
+		{"tag", db.TaskFilter{Tag: "work"}, []string{"Просрочена", "Позже"}},
+		{"range", db.TaskFilter{From: today(), To: days(5)}, []string{"Завтра", "Позже"}},
+		{"overdue", db.TaskFilter{Overdue: true}, []string{"Просрочена"}},
+		{"due before", db.TaskFilter{DueBefore: days(7)}, []string{"Позже"}},
+		{"ids", db.TaskFilter{IDs: []string{ids[1], ids[3]}}, []string{"Завтра", "Draft plan"}},
+		{"field", db.TaskFilter{Fields: map[string]any{"points": 5.0}}, []string{"Позже"}},
+		{"query tag", db.TaskFilter{Query: query("tag:work priority>=high")}, []string{"Просрочена"}},
+		{"query negate", db.TaskFilter{Query: query("-tag:work")}, []string{"Завтра", "Draft plan"}},
+		{"query word", db.TaskFilter{Query: query("хлеб")}, []string{"Завтра"}},
+		{"query title", db.TaskFilter{Query: query("title:DRAFT")}, []string{"Draft plan"}},
+		{"query repeat", db.TaskFilter{Query: query("repeat:w")}, []string{"Завтра"}},
+		{"query date", db.TaskFilter{Query: query("date>today has:deadline")}, []string{"Позже"}},
+		{"query is", db.TaskFilter{Query: query("is:overdue")}, []string{"Просрочена"}},
+		{"query has notes", db.TaskFilter{Query: query("has:notes")}, []string{"Draft plan"}},
+		{"query field", db.TaskFilter{Query: query("field.points>4")}, []string{"Позже", "Draft plan"}},
+		{"query field bool", db.TaskFilter{Query: query("field.done=true")}, []string{"Draft plan"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := s.GetTasks(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, titles(tasks))
+		})
+	}
+}
+
+func testPages(t *testing.T, s db.TaskStore) {
+	insert(t, s,
+		task.Task{Date: days(3), Title: "b", Priority: 1, Fields: map[string]any{"points": 2.0}},
+		task.Task{Date: days(1), Title: "d", Priority: 3},
+		task.Task{Date: days(2), Title: "a", Priority: 3, Fields: map[string]any{"points": 1.0}},
+		task.Task{Date: days(1), Title: "c"},
+		task.Task{Date: days(4), Title: "e", Priority: 2, Fields: map[string]any{"points": 3.0}},
+	)
+
+	sorts := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"d", "c", "a", "b", "e"}},
+		{"-date", []string{"e", "b", "a", "c", "d"}},
+		{"title", []string{"a", "b", "c", "d", "e"}},
+		{"-priority", []string{"a", "d", "e", "b", "c"}},
+		{"created", []string{"b", "d", "a", "c", "e"}},
+		{"field.points", []string{"d", "c", "a", "b", "e"}},
+		{"-field.points", []string{"e", "b", "a", "c", "d"}},
+	}
+	for _, tt := range sorts {
+		t.Run("sort "+tt.sort, func(t *testing.T) {
+			f := db.TaskFilter{Sort: tt.sort}
+
+			// Страницы по две задачи вперёд, затем назад от последней.
+			var forward []string
+			var pages []db.TaskPage
+			p := db.Page{Limit: 2, Total: true}
+			for {
+				page, err := s.GetTaskPage(f, p)
+				require.NoError(t, err)
+				assert.Equal(t, 5, page.Total)
+				forward = append(forward, titles(page.Tasks)...)
+				pages = append(pages, page)
+				if page.Next == nil {
+					break
+				}
+				p.Cursor = page.Next
+			}
+			assert.Equal(t, tt.want, forward)
+			require.Len(t, pages, 3)
+
+			prev, err := s.GetTaskPage(f, db.Page{Limit: 2, Cursor: pages[2].Prev})
+			require.NoError(t, err)
+			assert.Equal(t, titles(pages[1].Tasks), titles(prev.Tasks))
+
+			// Курсор проходит через строку, как у клиента.
+			c, err := db.ParseCursor(prev.Prev.String())
+			require.NoError(t, err)
+			first, err := s.GetTaskPage(f, db.Page{Limit: 2, Cursor: c})
+			require.NoError(t, err)
+			assert.Equal(t, titles(pages[0].Tasks), titles(first.Tasks))
+			assert.Nil(t, first.Prev)
+		})
+	}
+
+	_, err := s.GetTaskPage(db.TaskFilter{Sort: "title"}, db.Page{Cursor: &db.Cursor{Key: []any{"a"}}})
+	assert.ErrorIs(t, err, db.ErrInvalidCursor)
+}
+
+func testDone(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s,
+		task.Task{Date: today(), Title: "Разовая"},
+		task.Task{Date: today(), Title: "Повторяется", Repeat: "d 2", Deadline: days(1)},
+	)
+
+	require.NoError(t, s.MarkTaskDone(ids[0], 0))
+	_, err := s.GetTask(ids[0])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	require.NoError(t, s.StartTimer(ids[1], time.Now()))
+	require.NoError(t, s.MarkTaskDone(ids[1], 0))
+	moved, err := s.GetTask(ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, days(2), moved.Date)
+	assert.Equal(t, days(3), moved.Deadline)
+	assert.ErrorIs(t, s.StopTimer(ids[1], time.Now()), db.ErrTimerNotRunning)
+
+	assert.ErrorIs(t, s.MarkTaskDone("999", 0), sql.ErrNoRows)
+}
+
+func testVersions(t *testing.T, s db.TaskStore) {
+	require.NoError(t, s.InsertFieldDef(task.FieldDef{Name: "points", Type: task.FieldNumber}))
+	ids := insert(t, s, task.Task{Date: today(), Title: "Задача", Repeat: "d 1", Fields: map[string]any{"points": 1.0}})
+	version := func() int64 {
+		got, err := s.GetTask(ids[0])
+		require.NoError(t, err)
+		return got.Version
+	}
+	assert.EqualValues(t, 1, version())
+
+	stale, err := s.GetTask(ids[0])
+	require.NoError(t, err)
+	fresh := stale
+	fresh.Title = "Первая правка"
+	n, err := s.UpdateTask(fresh)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	assert.EqualValues(t, 2, version())
+
+	// Правка по устаревшей версии не применяется.
+	stale.Title = "Вторая правка"
+	_, err = s.UpdateTask(stale)
+	assert.ErrorIs(t, err, db.ErrVersionConflict)
+	got, err := s.GetTask(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "Первая правка", got.Title)
+
+	// Версия 0 записывается без проверки.
+	stale.Version = 0
+	_, err = s.UpdateTask(stale)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, version())
+
+	// Выполнение и удаление по устаревшей версии тоже не применяются.
+	assert.ErrorIs(t, s.MarkTaskDone(ids[0], 2), db.ErrVersionConflict)
+	got, err = s.GetTask(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, today(), got.Date)
+	assert.EqualValues(t, 3, got.Version)
+
+	require.NoError(t, s.MarkTaskDone(ids[0], 3))
+	assert.EqualValues(t, 4, version())
+	_, err = s.DeleteFieldDef("points")
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, version())
+
+	_, err = s.DeleteTask(ids[0], 4)
+	assert.ErrorIs(t, err, db.ErrVersionConflict)
+	_, err = s.GetTask(ids[0])
+	require.NoError(t, err)
+
+	once := insert(t, s, task.Task{Date: today(), Title: "Разовая задача"})
+	assert.ErrorIs(t, s.MarkTaskDone(once[0], 2), db.ErrVersionConflict)
+	require.NoError(t, s.MarkTaskDone(once[0], 1))
+	_, err = s.GetTask(once[0])
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	var steps []db.UndoStep
+	n, err = s.Recording(&steps).DeleteTask(ids[0], 5)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	// Удаление тоже меняет версию, и после восстановления она продолжает
+	// расти: ETag, выданный до удаления, не подходит.
+	require.NoError(t, s.Undo(steps))
+	assert.EqualValues(t, 7, version())
+	for _, old := range []int64{1, 5, 6} {
+		stale.Version = old
+		_, err = s.UpdateTask(stale)
+		assert.ErrorIs(t, err, db.ErrVersionConflict)
+	}
+	_, err = s.DeleteTask(ids[0], 5)
+	assert.ErrorIs(t, err, db.ErrVersionConflict)
+
+	_, err = s.DeleteTask(ids[0], 0)
+	require.NoError(t, err)
+	revisions, err := s.GetRevisions(ids[0])
+	require.NoError(t, err)
+	_, err = s.RevertTask(strconv.FormatInt(revisions[1].ID, 10))
+	require.NoError(t, err)
+	assert.EqualValues(t, 9, version())
+}
+
+func testRevisions(t *testing.T, s db.TaskStore) {
+	id, err := s.As("alice").InsertTask(task.Task{Date: today(), Title: "Первая версия"})
+	require.NoError(t, err)
+	taskID := strconv.FormatInt(id, 10)
+
+	_, err = s.As("bob").UpdateTask(task.Task{ID: taskID, Date: today(), Title: "Вторая версия"})
+	require.NoError(t, err)
+	// Сохранение без изменений не попадает в историю.
+	_, err = s.UpdateTask(task.Task{ID: taskID, Date: today(), Title: "Вторая версия"})
+	require.NoError(t, err)
+
+	revisions, err := s.GetRevisions(taskID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, db.RevisionUpdate, revisions[0].Action)
+	assert.Equal(t, "bob", revisions[0].Author)
+	assert.Equal(t, []task.Change{{Field: "title", Old: "Первая версия", New: "Вторая версия"}}, revisions[0].Changes)
+	assert.Equal(t, db.RevisionCreate, revisions[1].Action)
+	assert.Equal(t, "alice", revisions[1].Author)
+
+	_, err = s.DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	reverted, err := s.RevertTask(strconv.FormatInt(revisions[1].ID, 10))
+	require.NoError(t, err)
+	assert.Equal(t, "Первая версия", reverted.Title)
+	got, err := s.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "Первая версия", got.Title)
+
+	_, err = s.RevertTask("999")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Undo отменяет все записанные изменения, начиная с последнего.
+	var steps []db.UndoStep
+	recording := s.Recording(&steps)
+	_, err = recording.UpdateTask(task.Task{ID: taskID, Date: today(), Title: "Третья версия"})
+	require.NoError(t, err)
+	newID, err := recording.InsertTask(task.Task{Date: today(), Title: "Новая"})
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+
+	require.NoError(t, s.Undo(steps))
+	got, err = s.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "Первая версия", got.Title)
+	_, err = s.GetTask(strconv.FormatInt(newID, 10))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	revisions, err = s.GetRevisions(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, db.RevisionUndo, revisions[0].Action)
+}
+
+// testUndoConflict проверяет, что изменение не отменяется, если задачу
+// после него изменил или удалил другой запрос.
+func testUndoConflict(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s, task.Task{Date: today(), Title: "Исходная"})
+	taskID := ids[0]
+
+	var steps []db.UndoStep
+	_, err := s.Recording(&steps).UpdateTask(task.Task{ID: taskID, Date: today(), Title: "Моя правка"})
+	require.NoError(t, err)
+	_, err = s.UpdateTask(task.Task{ID: taskID, Date: today(), Title: "Чужая правка"})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.Undo(steps), db.ErrVersionConflict)
+	got, err := s.GetTask(taskID)
+	require.NoError(t, err)
+	assert.Equal(t, "Чужая правка", got.Title)
+
+	// Созданную задачу, которую затем изменили, отмена не удаляет.
+	steps = nil
+	newID, err := s.Recording(&steps).InsertTask(task.Task{Date: today(), Title: "Новая"})
+	require.NoError(t, err)
+	created := strconv.FormatInt(newID, 10)
+	_, err = s.UpdateTask(task.Task{ID: created, Date: today(), Title: "Новая, изменённая"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Undo(steps), db.ErrVersionConflict)
+	_, err = s.GetTask(created)
+	require.NoError(t, err)
+
+	// Удалённую после правки задачу отмена не восстанавливает.
+	steps = nil
+	_, err = s.Recording(&steps).UpdateTask(task.Task{ID: taskID, Date: today(), Title: "Ещё правка"})
+	require.NoError(t, err)
+	_, err = s.DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	assert.ErrorIs(t, s.Undo(steps), db.ErrVersionConflict)
+	_, err = s.GetTask(taskID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	// Если после операции задачу не трогали, отмена проходит, в том числе
+	// когда операция меняла задачу несколько раз.
+	steps = nil
+	recording := s.Recording(&steps)
+	_, err = recording.UpdateTask(task.Task{ID: created, Date: today(), Title: "Первая"})
+	require.NoError(t, err)
+	_, err = recording.UpdateTask(task.Task{ID: created, Date: today(), Title: "Вторая"})
+	require.NoError(t, err)
+	require.NoError(t, s.Undo(steps))
+	got, err = s.GetTask(created)
+	require.NoError(t, err)
+	assert.Equal(t, "Новая, изменённая", got.Title)
+}
+
+// testDeletedData проверяет, что отмена удаления, отмена выполнения разовой
+// задачи и откат к ревизии возвращают задачу вместе с её данными.
+func testDeletedData(t *testing.T, s db.TaskStore) {
+	require.NoError(t, s.SetAttachmentsDir(t.TempDir()))
+	ids := insert(t, s, task.Task{Date: today(), Title: "С данными", Tags: []string{"work"}, Estimate: 30})
+	taskID := ids[0]
+	note, err := s.InsertNote(taskID, "заметка")
+	require.NoError(t, err)
+	attachment, err := s.InsertAttachment(db.Attachment{TaskID: taskID, Name: "a.txt"}, []byte("hello"))
+	require.NoError(t, err)
+	start := time.Now().Add(-time.Hour)
+	require.NoError(t, s.StartTimer(taskID, start))
+
+	check := func() {
+		t.Helper()
+		notes, err := s.GetNotes(taskID)
+		require.NoError(t, err)
+		require.Len(t, notes, 1)
+		assert.Equal(t, note, notes[0].ID)
+		_, data, err := s.GetAttachment(strconv.FormatInt(attachment, 10))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("hello"), data)
+		report, err := s.TimeReport(db.ReportByTag, "", "", time.Now())
+		require.NoError(t, err)
+		require.Len(t, report, 1)
+		assert.Equal(t, "work", report[0].Key)
+		assert.Equal(t, 30, report[0].Estimate)
+	}
+
+	var steps []db.UndoStep
+	_, err = s.Recording(&steps).DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	notes, err := s.GetNotes(taskID)
+	require.NoError(t, err)
+	assert.Empty(t, notes)
+	_, err = s.GetNote(strconv.FormatInt(note, 10))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, _, err = s.GetAttachment(strconv.FormatInt(attachment, 10))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	n, err := s.DeleteNote(strconv.FormatInt(note, 10))
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	require.NoError(t, s.Undo(steps))
+	check()
+	// Таймер удалённой задачи остановлен и после отмены не продолжается.
+	assert.ErrorIs(t, s.StopTimer(taskID, time.Now()), db.ErrTimerNotRunning)
+
+	steps = nil
+	require.NoError(t, s.Recording(&steps).MarkTaskDone(taskID, 0))
+	require.NoError(t, s.Undo(steps))
+	check()
+
+	_, err = s.DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	revisions, err := s.GetRevisions(taskID)
+	require.NoError(t, err)
+	_, err = s.RevertTask(strconv.FormatInt(revisions[1].ID, 10))
+	require.NoError(t, err)
+	check()
+
+	// Недавно удалённые задачи не очищаются, давно удалённые — очищаются.
+	_, err = s.DeleteTask(taskID, 0)
+	require.NoError(t, err)
+	purged, err := s.PurgeDeleted(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	purged, err = s.PurgeDeleted(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	_, err = s.RevertTask(strconv.FormatInt(revisions[1].ID, 10))
+	require.NoError(t, err)
+	notes, err = s.GetNotes(taskID)
+	require.NoError(t, err)
+	assert.Empty(t, notes)
+}
+
+func testReschedule(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s,
+		task.Task{Date: days(1), Title: "a", Tags: []string{"work"}},
+		task.Task{Date: days(2), Title: "b", Tags: []string{"work"}, Deadline: days(4)},
+		task.Task{Date: days(3), Title: "c"},
+		task.Task{Date: "20200101", Title: "старая", Repeat: "y"},
+	)
+
+	result, err := s.Reschedule(db.TaskFilter{Tag: "work"}, db.RescheduleAction{Type: db.RescheduleShift, Days: 2}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []db.DateChange{
+		{ID: ids[0], Title: "a", OldDate: days(1), NewDate: days(3)},
+		{ID: ids[1], Title: "b", OldDate: days(2), NewDate: days(4)},
+	}, result.Moved)
+	assert.Empty(t, result.Skipped)
+	// Массовый перенос не сдвигает крайний срок.
+	b, err := s.GetTask(ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, days(4), b.Deadline)
+
+	// Задача, которая ушла бы за крайний срок, остаётся на месте.
+	result, err = s.Reschedule(db.TaskFilter{Tag: "work"}, db.RescheduleAction{Type: db.RescheduleShift, Days: 2}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, []db.DateChange{{ID: ids[0], Title: "a", OldDate: days(3), NewDate: days(5)}}, result.Moved)
+	assert.Equal(t, []db.DateChange{{ID: ids[1], Title: "b", OldDate: days(4), NewDate: days(6), Deadline: days(4)}}, result.Skipped)
+	b, err = s.GetTask(ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, days(4), b.Date)
+	assert.Equal(t, days(4), b.Deadline)
+
+	moved, err := s.RolloverOverdue(time.Now())
+	require.NoError(t, err)
+	require.Len(t, moved, 1)
+	assert.Equal(t, ids[3], moved[0].ID)
+	assert.GreaterOrEqual(t, moved[0].NewDate, today())
+
+	revisions, err := s.GetRevisions(ids[3])
+	require.NoError(t, err)
+	assert.Equal(t, db.RolloverAuthor, revisions[0].Author)
+}
+
+func testTimers(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s,
+		task.Task{Date: today(), Title: "a", Tags: []string{"work"}, Estimate: 60},
+		task.Task{Date: today(), Title: "b", Estimate: 15},
+	)
+	start := time.Now().Add(-2 * time.Hour)
+
+	require.NoError(t, s.StartTimer(ids[0], start))
+	assert.ErrorIs(t, s.StartTimer(ids[0], start), db.ErrTimerRunning)
+	require.NoError(t, s.StopTimer(ids[0], start.Add(90*time.Minute)))
+	assert.ErrorIs(t, s.StopTimer(ids[0], start), db.ErrTimerNotRunning)
+	require.NoError(t, s.StartTimer(ids[1], start))
+	assert.ErrorIs(t, s.StartTimer("999", start), sql.ErrNoRows)
+
+	report, err := s.TimeReport(db.ReportByTag, "", "", start.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []db.TimeReportRow{
+		{Key: "", Tracked: 30, Estimate: 15},
+		{Key: "work", Tracked: 90, Estimate: 60},
+	}, report)
+
+	// Учтённое время выполненной разовой задачи остаётся в отчёте с её оценкой и метками.
+	require.NoError(t, s.MarkTaskDone(ids[0], 0))
+	report, err = s.TimeReport(db.ReportByTag, "", "", start.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []db.TimeReportRow{
+		{Key: "", Tracked: 30, Estimate: 15},
+		{Key: "work", Tracked: 90, Estimate: 60},
+	}, report)
+
+	report, err = s.TimeReport(db.ReportByDay, days(1), "", time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, report)
+}
+
+func testPomodoro(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s, task.Task{Date: today(), Title: "Фокус"})
+
+	_, err := s.ActivePomodoro()
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	id, err := s.InsertPomodoro(db.PomodoroSession{TaskID: ids[0], Kind: db.PomodoroFocus, StartedAt: start, EndsAt: start.Add(25 * time.Minute)})
+	require.NoError(t, err)
+
+	active, err := s.ActivePomodoro()
+	require.NoError(t, err)
+	assert.Equal(t, id, active.ID)
+	assert.True(t, active.StartedAt.Equal(start))
+
+	require.NoError(t, s.FinishPomodoro(id, start.Add(25*time.Minute), true))
+	_, err = s.ActivePomodoro()
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	summary, err := s.FocusSummary(start)
+	require.NoError(t, err)
+	assert.Equal(t, []db.FocusSummaryRow{{TaskID: ids[0], Title: "Фокус", Sessions: 1, Minutes: 25}}, summary)
+
+	require.NoError(t, s.MarkTaskDone(ids[0], 0))
+	summary, err = s.FocusSummary(start)
+	require.NoError(t, err)
+	assert.Equal(t, []db.FocusSummaryRow{{TaskID: ids[0], Title: "Фокус", Sessions: 1, Minutes: 25}}, summary)
+}
+
+func testAttachments(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s, task.Task{Date: today(), Title: "С файлами"})
+
+	id, err := s.InsertAttachment(db.Attachment{TaskID: ids[0], Name: "a.txt", ContentType: "text/plain"}, []byte("hello"))
+	require.NoError(t, err)
+	_, err = s.InsertAttachment(db.Attachment{TaskID: "999", Name: "b.txt"}, nil)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	list, err := s.GetAttachments(ids[0])
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "a.txt", list[0].Name)
+	assert.EqualValues(t, 5, list[0].Size)
+
+	a, data, err := s.GetAttachment(strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain", a.ContentType)
+	assert.Equal(t, []byte("hello"), data)
+
+	// Файлы вложений удалённой задачи удаляются, когда PurgeDeleted очищает её
+	// данные после срока хранения.
+	dir := t.TempDir()
+	require.NoError(t, s.SetAttachmentsDir(dir))
+	fileID, err := s.InsertAttachment(db.Attachment{TaskID: ids[0], Name: "c.txt"}, []byte("file"))
+	require.NoError(t, err)
+	_, data, err = s.GetAttachment(strconv.FormatInt(fileID, 10))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("file"), data)
+
+	n, err := s.DeleteAttachment(strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = s.DeleteTask(ids[0], 0)
+	require.NoError(t, err)
+	_, _, err = s.GetAttachment(strconv.FormatInt(fileID, 10))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// Пока срок хранения не истёк, файл остаётся для отмены удаления.
+	purged, err := s.PurgeDeleted(time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+	files, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	purged, err = s.PurgeDeleted(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	files, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func testNotes(t *testing.T, s db.TaskStore) {
+	ids := insert(t, s, task.Task{Date: days(1), Title: "С заметками"})
+
+	id, err := s.InsertNote(ids[0], "первая")
+	require.NoError(t, err)
+	_, err = s.InsertNote("999", "нет задачи")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+
+	n, err := s.UpdateNote(strconv.FormatInt(id, 10), "исправлена")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	note, err := s.GetNote(strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.Equal(t, "исправлена", note.Body)
+	assert.Equal(t, days(1), note.TaskDate)
+
+	notes, err := s.GetNotes(ids[0])
+	require.NoError(t, err)
+	assert.Len(t, notes, 1)
+
+	n, err = s.DeleteNote(strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = s.GetNote(strconv.FormatInt(id, 10))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testFields(t *testing.T, s db.TaskStore) {
+	require.NoError(t, s.InsertFieldDef(task.FieldDef{Name: "stage", Type: task.FieldEnum, Options: []string{"new", "done"}}))
+	require.NoError(t, s.InsertFieldDef(task.FieldDef{Name: "points", Type: task.FieldNumber}))
+	assert.Error(t, s.InsertFieldDef(task.FieldDef{Name: "points", Type: task.FieldText}))
+
+	n, err := s.UpdateFieldDef(task.FieldDef{Name: "stage", Options: []string{"new", "wip", "done"}, Tag: "work"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	defs, err := s.GetFieldDefs()
+	require.NoError(t, err)
+	assert.Equal(t, []task.FieldDef{
+		{Name: "points", Type: task.FieldNumber},
+		{Name: "stage", Type: task.FieldEnum, Options: []string{"new", "wip", "done"}, Tag: "work"},
+	}, defs)
+
+	ids := insert(t, s, task.Task{Date: today(), Title: "a", Fields: map[string]any{"points": 2.0, "stage": "new"}})
+	n, err = s.DeleteFieldDef("points")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	got, err := s.GetTask(ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"stage": "new"}, got.Fields)
+}
+
+func testTemplates(t *testing.T, s db.TaskStore) {
+	id, err := s.InsertTemplate(templates.Template{Name: "Релиз", Tasks: []templates.TaskSpec{{Title: "Собрать", Date: "+1d"}}})
+	require.NoError(t, err)
+	_, err = s.InsertTemplate(templates.Template{Name: "Переезд", Tasks: []templates.TaskSpec{{Title: "Упаковать"}}})
+	require.NoError(t, err)
+
+	list, err := s.GetTemplates()
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Переезд", list[0].Name)
+
+	tmpl, err := s.GetTemplate(strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.Equal(t, []templates.TaskSpec{{Title: "Собрать", Date: "+1d"}}, tmpl.Tasks)
+
+	tmpl.Description = "Шаги релиза"
+	n, err := s.UpdateTemplate(tmpl)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	n, err = s.DeleteTemplate(tmpl.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = s.GetTemplate(tmpl.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testViews(t *testing.T, s db.TaskStore) {
+	id, err := s.InsertView(db.View{Name: "Работа", Params: map[string]string{"tag": "work"}})
+	require.NoError(t, err)
+	_, err = s.InsertView(db.View{Name: "Дом", Params: map[string]string{"q": "tag:home"}})
+	require.NoError(t, err)
+
+	views, err := s.GetViews()
+	require.NoError(t, err)
+	require.Len(t, views, 2)
+	assert.Equal(t, "Дом", views[0].Name)
+
+	v, err := s.GetView(strconv.FormatInt(id, 10))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"tag": "work"}, v.Params)
+
+	v.Params = map[string]string{"sort": "title"}
+	n, err := s.UpdateView(v)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+
+	n, err = s.DeleteView(v.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	_, err = s.GetView(v.ID)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func testSearch(t *testing.T, s db.TaskStore) {
+	insert(t, s,
+		task.Task{Date: days(1), Title: "Купить ёлку"},
+		task.Task{Date: days(2), Title: "Позвонить", Comment: "про елку"},
+		task.Task{Date: days(3), Title: "Другое"},
+	)
+
+	page, err := s.SearchTasks("ЕЛКУ", db.TaskFilter{}, db.Page{Total: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Купить ёлку", "Позвонить"}, titles(page.Tasks))
+	assert.Equal(t, 2, page.Total)
+	for _, found := range page.Tasks {
+		assert.Contains(t, found.Snippet, "<mark>")
+	}
+
+	page, err = s.SearchTasks("елку", db.TaskFilter{From: days(2)}, db.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Позвонить"}, titles(page.Tasks))
+
+	// Поиск следует за правкой и удалением задач.
+	other := page.Tasks[0]
+	other.Comment = "про подарки"
+	_, err = s.UpdateTask(other)
+	require.NoError(t, err)
+	page, err = s.SearchTasks("подарки", db.TaskFilter{}, db.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Позвонить"}, titles(page.Tasks))
+	_, err = s.DeleteTask(other.ID, 0)
+	require.NoError(t, err)
+	page, err = s.SearchTasks("подарки", db.TaskFilter{}, db.Page{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Tasks)
+	page, err = s.SearchTasks("елку", db.TaskFilter{}, db.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Купить ёлку"}, titles(page.Tasks))
+}
+
+// TestSQLiteSearchIndex проверяет, что на scheduler нет триггеров индекса
+// поиска, поэтому в таблицу может писать любой клиент SQLite, а его записи
+// находятся после перезапуска сервера.
+func TestSQLiteSearchIndex(t *testing.T) {
+	skipWithoutSQLite(t)
+	path := filepath.Join(t.TempDir(), "scheduler.db")
+	database, err := db.InitDB(path)
+	require.NoError(t, err)
+
+	var triggers int
+	require.NoError(t, database.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'scheduler'`).
+		Scan(&triggers))
+	assert.Zero(t, triggers)
+
+	_, err = database.Exec(`INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, 'Записана снаружи', '', '')`, today())
+	require.NoError(t, err)
+	require.NoError(t, database.Close())
+
+	database, err = db.InitDB(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	page, err := db.NewTaskStorage(database).SearchTasks("снаружи", db.TaskFilter{}, db.Page{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Записана снаружи"}, titles(page.Tasks))
+}
+
+func testSeed(t *testing.T, s db.TaskStore) {
+	path := filepath.Join(t.TempDir(), "seed.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"title": "Демо", "repeat": "d 1"}, {"title": "Вторая", "tags": ["Demo"]}]`), 0o644))
+
+	n, err := db.Seed(s, path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	tasks, err := s.GetTasks(db.TaskFilter{Tag: "demo"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Вторая"}, titles(tasks))
+
+	// В непустое хранилище начальные данные не загружаются повторно.
+	n, err = db.Seed(s, path)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+	"github.com/imbalaancing/go_final_project/internal/templates"
+)
+
+func (s *Storage) InsertTemplate(t templates.Template) (int64, error) {
+	body, err := json.Marshal(t.Tasks)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = s.db.QueryRow(`INSERT INTO task_templates (name, description, tasks) VALUES (?, ?, ?) RETURNING id`,
+		t.Name, t.Description, string(body)).Scan(&id)
+	return id, err
+}
+
+func (s *Storage) GetTemplates() ([]templates.Template, error) {
+	rows, err := s.db.Query(`SELECT id, name, description, tasks FROM task_templates ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]templates.Template, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+	return list, rows.Err()
+}
+
+func (s *Storage) GetTemplate(id string) (templates.Template, error) {
+	return scanTemplate(s.db.QueryRow(`SELECT id, name, description, tasks FROM task_templates WHERE id = ?`, id))
+}
+
+func (s *Storage) UpdateTemplate(t templates.Template) (int64, error) {
+	body, err := json.Marshal(t.Tasks)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.Exec(`UPDATE task_templates SET name = ?, description = ?, tasks = ? WHERE id = ?`,
+		t.Name, t.Description, string(body), t.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) DeleteTemplate(id string) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM task_templates WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// InsertTasks создаёт все задачи в одной транзакции и возвращает их идентификаторы.
+func (s *Storage) InsertTasks(tasks []task.Task) ([]string, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		id, err := s.insertTask(tx, t)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	return ids, tx.Commit()
+}
+
+func scanTemplate(row rowScanner) (templates.Template, error) {
+	var t templates.Template
+	var body string
+	if err := row.Scan(&t.ID, &t.Name, &t.Description, &body); err != nil {
+		return t, err
+	}
+	err := json.Unmarshal([]byte(body), &t.Tasks)
+	return t, err
+}
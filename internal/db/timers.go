@@ -0,0 +1,165 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+)
+
+var (
+	ErrTimerRunning    = errors.New("таймер уже запущен")
+	ErrTimerNotRunning = errors.New("таймер не запущен")
+)
+
+const (
+	ReportByDay  = "day"
+	ReportByWeek = "week"
+	ReportByTag  = "tag"
+)
+
+// TimeReportRow — учтённое время и оценка для одной группы отчёта, в минутах.
+type TimeReportRow struct {
+	Key      string `json:"key"`
+	Tracked  int    `json:"tracked"`
+	Estimate int    `json:"estimate"`
+}
+
+// StartTimer запускает таймер задачи. У задачи может быть только один запущенный таймер.
+func (s *Storage) StartTimer(id string, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow(`SELECT id FROM scheduler WHERE id = ?`, id).Scan(new(int64)); err != nil {
+		return err
+	}
+
+	var running int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM time_entries WHERE task_id = ? AND stopped_at IS NULL`, id).Scan(&running)
+	if err != nil {
+		return err
+	}
+	if running > 0 {
+		return ErrTimerRunning
+	}
+
+	_, err = tx.Exec(`INSERT INTO time_entries (task_id, started_at) VALUES (?, ?)`, id, now.UTC().Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// StopTimer останавливает запущенный таймер задачи.
+func (s *Storage) StopTimer(id string, now time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stopped, err := stopTimer(tx, id, now)
+	if err != nil {
+		return err
+	}
+	if !stopped {
+		return ErrTimerNotRunning
+	}
+	return tx.Commit()
+}
+
+func stopTimer(tx *sqlTx, id string, now time.Time) (bool, error) {
+	res, err := tx.Exec(`UPDATE time_entries SET stopped_at = ? WHERE task_id = ? AND stopped_at IS NULL`,
+		now.UTC().Format(time.RFC3339), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// TimeReport группирует учтённое время по дням, неделям или меткам и сравнивает
+// его с оценками задач. Запущенные таймеры учитываются до момента now.
+func (s *Storage) TimeReport(groupBy, from, to string, now time.Time) ([]TimeReportRow, error) {
+	rows, err := s.db.Query(`SELECT e.task_id, e.started_at, e.stopped_at, COALESCE(m.estimate, 0),
+	COALESCE((SELECT GROUP_CONCAT(tt.tag, ',') FROM task_tags tt WHERE tt.task_id = e.task_id), '')
+FROM time_entries e LEFT JOIN task_meta m ON m.task_id = e.task_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tracked := make(map[string]time.Duration)
+	estimates := make(map[string]map[int64]int)
+	for rows.Next() {
+		var taskID int64
+		var started string
+		var stopped sql.NullString
+		var estimate int
+		var tags string
+		if err := rows.Scan(&taskID, &started, &stopped, &estimate, &tags); err != nil {
+			return nil, err
+		}
+
+		start, err := time.Parse(time.RFC3339, started)
+		if err != nil {
+			return nil, err
+		}
+		end := now
+		if stopped.Valid {
+			if end, err = time.Parse(time.RFC3339, stopped.String); err != nil {
+				return nil, err
+			}
+		}
+
+		day := start.Local().Format(date.DATE_FORMAT)
+		if (from != "" && day < from) || (to != "" && day > to) {
+			continue
+		}
+
+		var keys []string
+		switch groupBy {
+		case ReportByDay:
+			keys = []string{day}
+		case ReportByWeek:
+			year, week := start.Local().ISOWeek()
+			keys = []string{fmt.Sprintf("%d-W%02d", year, week)}
+		case ReportByTag:
+			if tags == "" {
+				keys = []string{""}
+			} else {
+				keys = strings.Split(tags, ",")
+			}
+		}
+
+		for _, key := range keys {
+			tracked[key] += end.Sub(start)
+			if estimates[key] == nil {
+				estimates[key] = make(map[int64]int)
+			}
+			estimates[key][taskID] = estimate
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := make([]TimeReportRow, 0, len(tracked))
+	for key, d := range tracked {
+		row := TimeReportRow{Key: key, Tracked: int(d.Round(time.Minute).Minutes())}
+		for _, e := range estimates[key] {
+			row.Estimate += e
+		}
+		report = append(report, row)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Key < report[j].Key })
+
+	return report, nil
+}
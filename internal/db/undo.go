@@ -0,0 +1,126 @@
+package db
+
+import (
+	"database/sql"
+
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+const RevisionUndo = "undo"
+
+// UndoStep — обратная операция для одного изменения задачи: вернуть задачу
+// к состоянию Before или удалить её, если до изменения её не было. Version —
+// версия задачи сразу после изменения (0, если изменение её удалило): если
+// задачу с тех пор изменили, отменять изменение нельзя.
+type UndoStep struct {
+	TaskID  string
+	Before  *task.Task
+	Version int64
+}
+
+// Recording возвращает хранилище, которое дописывает в steps обратные операции
+// для всех изменений задач.
+func (s *Storage) Recording(steps *[]UndoStep) TaskStore {
+	return s.recording(steps)
+}
+
+func (s *Storage) recording(steps *[]UndoStep) *Storage {
+	c := *s
+	c.undo = steps
+	return &c
+}
+
+func (s *Storage) recordUndo(tx *sqlTx, taskID string, before, after *task.Task) error {
+	if s.undo == nil {
+		return nil
+	}
+	step := UndoStep{TaskID: taskID}
+	if before != nil {
+		b := *before
+		step.Before = &b
+	}
+	if after != nil {
+		if err := tx.QueryRow(`SELECT version FROM task_meta WHERE task_id = ?`, taskID).Scan(&step.Version); err != nil {
+			return err
+		}
+	}
+	*s.undo = append(*s.undo, step)
+	return nil
+}
+
+// undoVersion проверяет, что задачу не меняли после изменения, которое
+// отменяет step, и возвращает версию для записи с проверкой. Задачу, уже
+// затронутую этой же отменой, повторно не проверяют.
+func undoVersion(step UndoStep, current *task.Task, undone map[string]bool) (int64, error) {
+	if undone[step.TaskID] {
+		return 0, nil
+	}
+	undone[step.TaskID] = true
+
+	if step.Version == 0 {
+		if current != nil {
+			return 0, ErrVersionConflict
+		}
+		return 0, nil
+	}
+	if current == nil {
+		if step.Before == nil {
+			return 0, nil
+		}
+		return 0, ErrVersionConflict
+	}
+	if current.Version != step.Version {
+		return 0, ErrVersionConflict
+	}
+	return step.Version, nil
+}
+
+// Undo выполняет обратные операции в одной транзакции, начиная с последней.
+// Если какую-то из задач изменили после записанного изменения, не отменяется
+// ничего и возвращается ErrVersionConflict.
+func (s *Storage) Undo(steps []UndoStep) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	s = s.recording(nil)
+	undone := make(map[string]bool)
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+
+		var current *task.Task
+		if t, err := getTask(tx, step.TaskID); err == nil {
+			current = &t
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+
+		version, err := undoVersion(step, current, undone)
+		if err != nil {
+			return err
+		}
+
+		if step.Before == nil {
+			if current == nil {
+				continue
+			}
+			if _, err := removeTask(tx, step.TaskID, version); err != nil {
+				return err
+			}
+		} else {
+			before := *step.Before
+			before.Version = version
+			if err := restoreTask(tx, before); err != nil {
+				return err
+			}
+		}
+
+		if err := s.recordRevision(tx, step.TaskID, current, step.Before, RevisionUndo); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,73 @@
+package db
+
+import "encoding/json"
+
+// View — сохранённое представление: именованный набор параметров списка задач
+// GET /api/tasks, например {"q": "date<=today tag:work"}.
+type View struct {
+	ID     string            `json:"id"`
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params"`
+}
+
+func (s *Storage) InsertView(v View) (int64, error) {
+	params, err := json.Marshal(v.Params)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = s.db.QueryRow(`INSERT INTO task_views (name, params) VALUES (?, ?) RETURNING id`, v.Name, string(params)).Scan(&id)
+	return id, err
+}
+
+func (s *Storage) GetViews() ([]View, error) {
+	rows, err := s.db.Query(`SELECT id, name, params FROM task_views ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := make([]View, 0)
+	for rows.Next() {
+		v, err := scanView(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (s *Storage) GetView(id string) (View, error) {
+	return scanView(s.db.QueryRow(`SELECT id, name, params FROM task_views WHERE id = ?`, id))
+}
+
+func (s *Storage) UpdateView(v View) (int64, error) {
+	params, err := json.Marshal(v.Params)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.db.Exec(`UPDATE task_views SET name = ?, params = ? WHERE id = ?`, v.Name, string(params), v.ID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *Storage) DeleteView(id string) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM task_views WHERE id = ?`, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanView(row rowScanner) (View, error) {
+	var v View
+	var params string
+	if err := row.Scan(&v.ID, &v.Name, &params); err != nil {
+		return v, err
+	}
+	err := json.Unmarshal([]byte(params), &v.Params)
+	return v, err
+}
@@ -0,0 +1,21 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Без опции WithUnsafe goldmark не пропускает сырой HTML и опасные ссылки
+// вроде javascript:, поэтому результат можно вставлять в страницу как есть.
+var renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// ToHTML преобразует Markdown в безопасный HTML.
+func ToHTML(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
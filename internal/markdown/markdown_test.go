@@ -0,0 +1,45 @@
+package markdown_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/markdown"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"**жирный** и `код`", "<p><strong>жирный</strong> и <code>код</code></p>\n"},
+		{"~~зачёркнуто~~", "<p><del>зачёркнуто</del></p>\n"},
+		{"- [x] готово", "<ul>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\"> готово</li>\n</ul>\n"},
+		{"[сайт](https://example.com)", "<p><a href=\"https://example.com\">сайт</a></p>\n"},
+		{"https://example.com", "<p><a href=\"https://example.com\">https://example.com</a></p>\n"},
+		{"a & b < c", "<p>a &amp; b &lt; c</p>\n"},
+
+		// Сырой HTML не попадает в результат.
+		{"<script>alert(1)</script>", "<!-- raw HTML omitted -->\n"},
+		{"текст <script>alert(1)</script> дальше", "<p>текст <!-- raw HTML omitted -->alert(1)<!-- raw HTML omitted --> дальше</p>\n"},
+		{"<img src=x onerror=alert(1)>", "<!-- raw HTML omitted -->\n"},
+		{`<b onclick="steal()">жирный</b>`, "<p><!-- raw HTML omitted -->жирный<!-- raw HTML omitted --></p>\n"},
+
+		// Опасные ссылки остаются без адреса.
+		{"[ссылка](javascript:alert(1))", "<p><a href=\"\">ссылка</a></p>\n"},
+		{"[ссылка](JavaScript:alert(1))", "<p><a href=\"\">ссылка</a></p>\n"},
+		{"<javascript:alert(1)>", "<p><a href=\"\">javascript:alert(1)</a></p>\n"},
+		{"![картинка](javascript:alert(1))", "<p><img src=\"\" alt=\"картинка\"></p>\n"},
+		{"[ссылка](vbscript:msgbox(1))", "<p><a href=\"\">ссылка</a></p>\n"},
+		{"[ссылка](data:text/html;base64,PHNjcmlwdD4=)", "<p><a href=\"\">ссылка</a></p>\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := markdown.ToHTML(tt.source)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
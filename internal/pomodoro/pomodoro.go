@@ -0,0 +1,246 @@
+package pomodoro
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+const (
+	StateIdle  = "idle"
+	StateFocus = db.PomodoroFocus
+	StateBreak = db.PomodoroBreak
+)
+
+var ErrSessionActive = errors.New("сессия уже идёт")
+
+// Settings — длительности фокуса и перерывов. Длинный перерыв даётся после
+// каждых LongEvery завершённых фокус-сессий подряд.
+type Settings struct {
+	Focus      time.Duration
+	ShortBreak time.Duration
+	LongBreak  time.Duration
+	LongEvery  int
+}
+
+var DefaultSettings = Settings{
+	Focus:      25 * time.Minute,
+	ShortBreak: 5 * time.Minute,
+	LongBreak:  15 * time.Minute,
+	LongEvery:  4,
+}
+
+// State — состояние сессии, которое получают клиенты.
+type State struct {
+	State     string     `json:"state"`
+	TaskID    string     `json:"task_id,omitempty"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	Remaining int        `json:"remaining"` // секунд до конца сессии
+	Completed int        `json:"completed"` // фокус-сессий подряд
+}
+
+// Manager ведёт единственную текущую сессию. Часы сессии живут на сервере,
+// а сама сессия хранится в базе и восстанавливается после перезапуска.
+type Manager struct {
+	storage db.TaskStore
+
+	mu          sync.Mutex
+	current     *db.PomodoroSession
+	settings    Settings
+	completed   int
+	timer       *time.Timer
+	subscribers map[chan State]struct{}
+}
+
+func NewManager(storage db.TaskStore) (*Manager, error) {
+	m := &Manager{
+		storage:     storage,
+		settings:    DefaultSettings,
+		subscribers: make(map[chan State]struct{}),
+	}
+
+	active, err := storage.ActivePomodoro()
+	if errors.Is(err, sql.ErrNoRows) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().Before(active.EndsAt) {
+		m.current = &active
+		m.timer = time.AfterFunc(time.Until(active.EndsAt), m.expire)
+		return m, nil
+	}
+
+	// Сессия закончилась, пока сервер был остановлен.
+	if err := storage.FinishPomodoro(active.ID, active.EndsAt, true); err != nil {
+		return nil, err
+	}
+	if active.Kind == StateFocus {
+		stopTaskTimer(storage, active.TaskID, active.EndsAt)
+	}
+	return m, nil
+}
+
+// Start начинает фокус-сессию по задаче.
+func (m *Manager) Start(taskID string, settings Settings) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current != nil {
+		return m.stateLocked(), ErrSessionActive
+	}
+	if _, err := m.storage.GetTask(taskID); err != nil {
+		return State{}, err
+	}
+
+	m.settings = settings
+	m.completed = 0
+	if err := m.beginLocked(taskID, StateFocus, settings.Focus); err != nil {
+		return State{}, err
+	}
+	return m.stateLocked(), nil
+}
+
+// Stop прерывает текущую сессию. Прерванная сессия не считается завершённой.
+func (m *Manager) Stop() (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil {
+		return m.stateLocked(), nil
+	}
+	if err := m.finishLocked(time.Now(), false); err != nil {
+		return State{}, err
+	}
+	m.broadcastLocked()
+	return m.stateLocked(), nil
+}
+
+func (m *Manager) State() State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stateLocked()
+}
+
+// Subscribe возвращает канал с обновлениями состояния. Канал нужно вернуть через Unsubscribe.
+func (m *Manager) Subscribe() chan State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan State, 1)
+	ch <- m.stateLocked()
+	m.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (m *Manager) Unsubscribe(ch chan State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.subscribers, ch)
+}
+
+func (m *Manager) expire() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.current == nil || time.Now().Before(m.current.EndsAt) {
+		return
+	}
+
+	finished := *m.current
+	if err := m.finishLocked(finished.EndsAt, true); err != nil {
+		log.Printf("Ошибка завершения сессии: %v", err)
+		return
+	}
+
+	if finished.Kind == StateFocus {
+		m.completed++
+		pause := m.settings.ShortBreak
+		if m.settings.LongEvery > 0 && m.completed%m.settings.LongEvery == 0 {
+			pause = m.settings.LongBreak
+		}
+		if err := m.beginLocked(finished.TaskID, StateBreak, pause); err != nil {
+			log.Printf("Ошибка начала перерыва: %v", err)
+		}
+	}
+	m.broadcastLocked()
+}
+
+func (m *Manager) beginLocked(taskID, kind string, length time.Duration) error {
+	now := time.Now()
+	session := db.PomodoroSession{TaskID: taskID, Kind: kind, StartedAt: now, EndsAt: now.Add(length)}
+	id, err := m.storage.InsertPomodoro(session)
+	if err != nil {
+		return err
+	}
+	session.ID = id
+
+	if kind == StateFocus {
+		if err := m.storage.StartTimer(taskID, now); err != nil && !errors.Is(err, db.ErrTimerRunning) {
+			return err
+		}
+	}
+
+	m.current = &session
+	m.timer = time.AfterFunc(length, m.expire)
+	m.broadcastLocked()
+	return nil
+}
+
+func (m *Manager) finishLocked(at time.Time, completed bool) error {
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	if err := m.storage.FinishPomodoro(m.current.ID, at, completed); err != nil {
+		return err
+	}
+	if m.current.Kind == StateFocus {
+		stopTaskTimer(m.storage, m.current.TaskID, at)
+	}
+	m.current = nil
+	return nil
+}
+
+func (m *Manager) stateLocked() State {
+	if m.current == nil {
+		return State{State: StateIdle, Completed: m.completed}
+	}
+	remaining := time.Until(m.current.EndsAt)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return State{
+		State:     m.current.Kind,
+		TaskID:    m.current.TaskID,
+		StartedAt: &m.current.StartedAt,
+		EndsAt:    &m.current.EndsAt,
+		Remaining: int(remaining.Round(time.Second).Seconds()),
+		Completed: m.completed,
+	}
+}
+
+func (m *Manager) broadcastLocked() {
+	state := m.stateLocked()
+	for ch := range m.subscribers {
+		// Медленный клиент получает только последнее состояние.
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- state
+	}
+}
+
+func stopTaskTimer(storage db.TaskStore, taskID string, at time.Time) {
+	if err := storage.StopTimer(taskID, at); err != nil && !errors.Is(err, db.ErrTimerNotRunning) {
+		log.Printf("Ошибка остановки таймера задачи %s: %v", taskID, err)
+	}
+}
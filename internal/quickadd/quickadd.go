@@ -0,0 +1,399 @@
+// Package quickadd разбирает строку быстрого добавления задачи на русском или
+// английском, например «Оплатить интернет завтра каждый месяц 5 #дом !высокий»
+// или «Call Bob next Monday every 2 weeks».
+package quickadd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Fragment — распознанная часть строки и то, как она понята.
+type Fragment struct {
+	Text  string `json:"text"`
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+const (
+	KindDate     = "date"
+	KindRepeat   = "repeat"
+	KindTag      = "tag"
+	KindPriority = "priority"
+)
+
+// Result — задача, собранная из строки, и список распознанных фрагментов.
+type Result struct {
+	Task      task.Task  `json:"task"`
+	Fragments []Fragment `json:"fragments"`
+}
+
+type parser struct {
+	now    time.Time
+	words  []string // слова в исходном написании
+	lower  []string // те же слова в нижнем регистре без знаков препинания по краям
+	result Result
+
+	// monthDay — ежемесячное повторение задано без дня месяца.
+	monthDay bool
+}
+
+// Parse разбирает строку относительно момента now.
+func Parse(text string, now time.Time) (Result, error) {
+	p := &parser{now: now, words: strings.Fields(text)}
+	for _, w := range p.words {
+		p.lower = append(p.lower, strings.Trim(strings.ToLower(w), ",.;"))
+	}
+
+	var title []string
+	for i := 0; i < len(p.words); {
+		n := p.match(i)
+		if n == 0 {
+			title = append(title, p.words[i])
+			i++
+			continue
+		}
+		i += n
+	}
+
+	if p.monthDay && p.result.Task.Repeat == "m" {
+		day := p.now.Day()
+		if d, err := time.Parse(date.DATE_FORMAT, p.result.Task.Date); err == nil {
+			day = d.Day()
+		}
+		p.result.Task.Repeat = "m " + strconv.Itoa(day)
+		for k := range p.result.Fragments {
+			if p.result.Fragments[k].Kind == KindRepeat {
+				p.result.Fragments[k].Value = p.result.Task.Repeat
+			}
+		}
+	}
+
+	p.result.Task.Title = strings.TrimSpace(strings.Join(title, " "))
+	if p.result.Task.Title == "" {
+		return p.result, fmt.Errorf("не удалось выделить заголовок задачи")
+	}
+	if p.result.Fragments == nil {
+		p.result.Fragments = make([]Fragment, 0)
+	}
+	return p.result, nil
+}
+
+// match пробует распознать фрагмент, начинающийся со слова i, и возвращает
+// число поглощённых слов.
+func (p *parser) match(i int) int {
+	for _, m := range []func(int) int{p.matchTag, p.matchPriority, p.matchRepeat, p.matchDate} {
+		if n := m(i); n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+func (p *parser) add(i, n int, kind, value string) int {
+	p.result.Fragments = append(p.result.Fragments, Fragment{
+		Text:  strings.Join(p.words[i:i+n], " "),
+		Kind:  kind,
+		Value: value,
+	})
+	return n
+}
+
+func (p *parser) word(i int) string {
+	if i < len(p.lower) {
+		return p.lower[i]
+	}
+	return ""
+}
+
+func (p *parser) matchTag(i int) int {
+	w := p.words[i]
+	if len(w) < 2 || w[0] != '#' {
+		return 0
+	}
+	tag := strings.ToLower(strings.Trim(w[1:], ",.;"))
+	p.result.Task.Tags = append(p.result.Task.Tags, tag)
+	return p.add(i, 1, KindTag, tag)
+}
+
+var priorities = map[string]int{
+	"!": task.PriorityLow, "!1": task.PriorityLow, "!низкий": task.PriorityLow, "!low": task.PriorityLow,
+	"!!": task.PriorityMedium, "!2": task.PriorityMedium, "!средний": task.PriorityMedium, "!medium": task.PriorityMedium,
+	"!!!": task.PriorityHigh, "!3": task.PriorityHigh, "!высокий": task.PriorityHigh, "!high": task.PriorityHigh,
+}
+
+func (p *parser) matchPriority(i int) int {
+	priority, ok := priorities[p.word(i)]
+	if !ok {
+		return 0
+	}
+	p.result.Task.Priority = priority
+	return p.add(i, 1, KindPriority, strconv.Itoa(priority))
+}
+
+func (p *parser) matchRepeat(i int) int {
+	w := p.word(i)
+
+	switch w {
+	case "ежедневно", "daily":
+		return p.setRepeat(i, 1, "d 1")
+	case "еженедельно", "weekly":
+		return p.setRepeat(i, 1, "d 7")
+	case "ежемесячно", "monthly":
+		repeat, n := p.monthly(i + 1)
+		return p.setRepeat(i, 1+n, repeat)
+	case "ежегодно", "yearly", "annually":
+		return p.setRepeat(i, 1, "y")
+	case "каждый", "каждую", "каждое", "каждые", "every", "по":
+	default:
+		return 0
+	}
+
+	// «каждые 2 недели», «every 3 days»
+	j := i + 1
+	n := 1
+	if v, err := strconv.Atoi(p.word(j)); err == nil && v > 0 {
+		n = v
+		j++
+	} else if w == "every" && p.word(j) == "other" {
+		n = 2
+		j++
+	}
+
+	unit := p.word(j)
+	switch {
+	case contains(dayWords, unit):
+		return p.setRepeat(i, j-i+1, "d "+strconv.Itoa(n))
+	case contains(weekWords, unit):
+		return p.setRepeat(i, j-i+1, "d "+strconv.Itoa(7*n))
+	case contains(monthWords, unit) && n == 1:
+		repeat, extra := p.monthly(j + 1)
+		return p.setRepeat(i, j-i+1+extra, repeat)
+	case contains(yearWords, unit) && n == 1:
+		return p.setRepeat(i, j-i+1, "y")
+	}
+
+	// «каждый понедельник», «по понедельникам и средам», «every mon, wed»
+	if n != 1 {
+		return 0
+	}
+	var days []string
+	for {
+		d, ok := weekday(p.word(j))
+		if !ok {
+			break
+		}
+		days = append(days, strconv.Itoa(d))
+		j++
+		if sep := p.word(j); sep == "и" || sep == "and" {
+			j++
+		}
+	}
+	if len(days) == 0 {
+		return 0
+	}
+	if sep := p.word(j - 1); sep == "и" || sep == "and" {
+		j--
+	}
+	return p.setRepeat(i, j-i, "w "+strings.Join(days, ","))
+}
+
+// monthly разбирает необязательный день месяца в слове j и возвращает правило
+// повторения и число поглощённых слов. Без явного дня правило дополняется днём
+// даты задачи в Parse.
+func (p *parser) monthly(j int) (string, int) {
+	w := strings.TrimSuffix(strings.TrimSuffix(p.word(j), "-го"), "th")
+	if v, err := strconv.Atoi(w); err == nil && v >= 1 && v <= 31 {
+		return "m " + strconv.Itoa(v), 1
+	}
+	p.monthDay = true
+	return "m", 0
+}
+
+func (p *parser) setRepeat(i, n int, repeat string) int {
+	p.result.Task.Repeat = repeat
+	return p.add(i, n, KindRepeat, repeat)
+}
+
+var (
+	dotDatePattern = regexp.MustCompile(`^(\d{1,2})\.(\d{1,2})(?:\.(\d{4}))?$`)
+	isoDatePattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+)
+
+func (p *parser) matchDate(i int) int {
+	w := p.word(i)
+	today := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch w {
+	case "сегодня", "today":
+		return p.setDate(i, 1, today)
+	case "завтра", "tomorrow":
+		return p.setDate(i, 1, today.AddDate(0, 0, 1))
+	case "послезавтра":
+		return p.setDate(i, 1, today.AddDate(0, 0, 2))
+	case "через", "in":
+		// «через 3 дня», «через неделю», «in 2 weeks»
+		n, j := 1, i+1
+		if v, err := strconv.Atoi(p.word(j)); err == nil && v > 0 {
+			n = v
+			j++
+		}
+		switch unit := p.word(j); {
+		case contains(dayWords, unit):
+			return p.setDate(i, j-i+1, today.AddDate(0, 0, n))
+		case contains(weekWords, unit):
+			return p.setDate(i, j-i+1, today.AddDate(0, 0, 7*n))
+		case contains(monthWords, unit):
+			return p.setDate(i, j-i+1, today.AddDate(0, n, 0))
+		}
+		return 0
+	}
+
+	// «в пятницу», «в следующий понедельник», «next Monday», «on Monday»
+	j := i
+	if w == "в" || w == "во" || w == "on" {
+		j++
+	}
+	if contains(nextWords, p.word(j)) {
+		j++
+	}
+	if d, ok := weekday(p.word(j)); ok {
+		offset := (d - isoWeekday(today) + 7) % 7
+		if offset == 0 {
+			offset = 7
+		}
+		return p.setDate(i, j-i+1, today.AddDate(0, 0, offset))
+	}
+
+	if m := isoDatePattern.FindStringSubmatch(w); m != nil {
+		return p.setDayMonth(i, 1, atoi(m[3]), atoi(m[2]), atoi(m[1]))
+	}
+	if len(w) == 8 {
+		if d, err := time.Parse(date.DATE_FORMAT, w); err == nil {
+			return p.setDate(i, 1, d)
+		}
+	}
+	if m := dotDatePattern.FindStringSubmatch(w); m != nil {
+		return p.setDayMonth(i, 1, atoi(m[1]), atoi(m[2]), atoi(m[3]))
+	}
+
+	// «5 марта», «March 5», «5 March»
+	if day, err := strconv.Atoi(w); err == nil {
+		if month, ok := monthName(p.word(i + 1)); ok {
+			return p.setDayMonth(i, 2, day, month, 0)
+		}
+	}
+	if month, ok := monthName(w); ok {
+		if day, err := strconv.Atoi(strings.TrimSuffix(p.word(i+1), "th")); err == nil {
+			return p.setDayMonth(i, 2, day, month, 0)
+		}
+	}
+
+	return 0
+}
+
+// setDayMonth задаёт дату по дню и месяцу. Без года берётся ближайшая такая дата
+// не раньше сегодняшней.
+func (p *parser) setDayMonth(i, n, day, month, year int) int {
+	y := year
+	if y == 0 {
+		y = p.now.Year()
+	}
+	d := time.Date(y, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if d.Day() != day || d.Month() != time.Month(month) {
+		return 0
+	}
+	if year == 0 && d.Format(date.DATE_FORMAT) < p.now.Format(date.DATE_FORMAT) {
+		d = d.AddDate(1, 0, 0)
+	}
+	return p.setDate(i, n, d)
+}
+
+func (p *parser) setDate(i, n int, d time.Time) int {
+	value := d.Format(date.DATE_FORMAT)
+	p.result.Task.Date = value
+	return p.add(i, n, KindDate, value)
+}
+
+// Единицы времени, слово «следующий», названия дней недели и месяцев
+// сравниваются только целыми словами, иначе «средство» или «по средней»
+// принимаются за среду, а «по годовому плану» — за ежегодное повторение.
+// Русские слова перечислены в тех формах, в которых встречаются в датах и
+// повторениях: «в среду», «каждую среду», «по средам», «через 2 недели».
+var (
+	dayWords   = []string{"день", "дня", "дней", "day", "days"}
+	weekWords  = []string{"неделя", "неделю", "недели", "недель", "week", "weeks"}
+	monthWords = []string{"месяц", "месяца", "месяцев", "month", "months"}
+	yearWords  = []string{"год", "года", "лет", "year", "years"}
+	nextWords  = []string{"следующий", "следующую", "следующее", "следующей", "next"}
+)
+
+var weekdayNames = [][]string{
+	{"понедельник", "понедельникам", "пн", "mon", "monday"},
+	{"вторник", "вторникам", "вт", "tue", "tuesday"},
+	{"среда", "среду", "средам", "ср", "wed", "wednesday"},
+	{"четверг", "четвергам", "чт", "thu", "thursday"},
+	{"пятница", "пятницу", "пятницам", "пт", "fri", "friday"},
+	{"суббота", "субботу", "субботам", "сб", "sat", "saturday"},
+	{"воскресенье", "воскресеньям", "вс", "sun", "sunday"},
+}
+
+func weekday(w string) (int, bool) {
+	for i, words := range weekdayNames {
+		if contains(words, w) {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+var monthNames = [][]string{
+	{"январь", "января", "jan", "january"},
+	{"февраль", "февраля", "feb", "february"},
+	{"март", "марта", "mar", "march"},
+	{"апрель", "апреля", "apr", "april"},
+	{"май", "мая", "may"},
+	{"июнь", "июня", "jun", "june"},
+	{"июль", "июля", "jul", "july"},
+	{"август", "августа", "aug", "august"},
+	{"сентябрь", "сентября", "sep", "sept", "september"},
+	{"октябрь", "октября", "oct", "october"},
+	{"ноябрь", "ноября", "nov", "november"},
+	{"декабрь", "декабря", "dec", "december"},
+}
+
+func monthName(w string) (int, bool) {
+	for i, words := range monthNames {
+		if contains(words, w) {
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+func contains(words []string, w string) bool {
+	for _, word := range words {
+		if word == w {
+			return true
+		}
+	}
+	return false
+}
+
+func isoWeekday(d time.Time) int {
+	if d.Weekday() == time.Sunday {
+		return 7
+	}
+	return int(d.Weekday())
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
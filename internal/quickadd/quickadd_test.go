@@ -0,0 +1,92 @@
+package quickadd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/quickadd"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// now — среда, 15 января 2025 года.
+var now = time.Date(2025, time.January, 15, 10, 0, 0, 0, time.UTC)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want task.Task
+	}{
+		{"Оплатить интернет завтра каждый месяц 5 #дом !высокий",
+			task.Task{Title: "Оплатить интернет", Date: "20250116", Repeat: "m 5", Tags: []string{"дом"}, Priority: task.PriorityHigh}},
+		{"Call Bob next Monday every 2 weeks",
+			task.Task{Title: "Call Bob", Date: "20250120", Repeat: "d 14"}},
+
+		// Обычные слова, начинающиеся как дни недели, остаются в заголовке.
+		{"Купить средство для мытья посуды", task.Task{Title: "Купить средство для мытья посуды"}},
+		{"Пройти по средней дорожке", task.Task{Title: "Пройти по средней дорожке"}},
+		{"Настроить переменные в среде разработки", task.Task{Title: "Настроить переменные в среде разработки"}},
+		{"Разобрать пятничные заметки", task.Task{Title: "Разобрать пятничные заметки"}},
+		{"Сдать отчёт до среды", task.Task{Title: "Сдать отчёт до среды"}},
+		{"Отчёт по годовому плану", task.Task{Title: "Отчёт по годовому плану"}},
+		{"every daycare pickup", task.Task{Title: "every daycare pickup"}},
+		{"Отзыв через неделька", task.Task{Title: "Отзыв через неделька"}},
+		{"Купить 5 мартышек", task.Task{Title: "Купить 5 мартышек"}},
+		{"Встреча в следующем квартале", task.Task{Title: "Встреча в следующем квартале"}},
+
+		{"Позвонить маме в среду", task.Task{Title: "Позвонить маме", Date: "20250122"}},
+		{"Созвон во вторник", task.Task{Title: "Созвон", Date: "20250121"}},
+		{"Уборка в следующую субботу", task.Task{Title: "Уборка", Date: "20250118"}},
+		{"Ревью on Friday", task.Task{Title: "Ревью", Date: "20250117"}},
+		{"Отчёт сегодня", task.Task{Title: "Отчёт", Date: "20250115"}},
+		{"Отпуск через 2 недели", task.Task{Title: "Отпуск", Date: "20250129"}},
+		{"Отпуск через неделю", task.Task{Title: "Отпуск", Date: "20250122"}},
+		{"Визит через 3 дня", task.Task{Title: "Визит", Date: "20250118"}},
+		{"Налоги 5 марта", task.Task{Title: "Налоги", Date: "20250305"}},
+		{"Налоги March 5", task.Task{Title: "Налоги", Date: "20250305"}},
+		{"Праздник 05.01", task.Task{Title: "Праздник", Date: "20260105"}},
+		{"Релиз 2025-02-01", task.Task{Title: "Релиз", Date: "20250201"}},
+
+		{"Йога по понедельникам и средам", task.Task{Title: "Йога", Repeat: "w 1,3"}},
+		{"Стирка каждую субботу", task.Task{Title: "Стирка", Repeat: "w 6"}},
+		{"Stand-up every Monday and Friday", task.Task{Title: "Stand-up", Repeat: "w 1,5"}},
+		{"Полить цветы каждые 3 дня", task.Task{Title: "Полить цветы", Repeat: "d 3"}},
+		{"Планёрка еженедельно", task.Task{Title: "Планёрка", Repeat: "d 7"}},
+		{"Аренда 10.02 ежемесячно", task.Task{Title: "Аренда", Date: "20250210", Repeat: "m 10"}},
+		{"Страховка every year", task.Task{Title: "Страховка", Repeat: "y"}},
+		{"Техосмотр каждый год", task.Task{Title: "Техосмотр", Repeat: "y"}},
+		{"Бассейн every 2 days", task.Task{Title: "Бассейн", Repeat: "d 2"}},
+
+		{"Прочитать книгу #чтение #дом !", task.Task{Title: "Прочитать книгу", Tags: []string{"чтение", "дом"}, Priority: task.PriorityLow}},
+		{"Fix bug !!", task.Task{Title: "Fix bug", Priority: task.PriorityMedium}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := quickadd.Parse(tt.text, now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Task)
+		})
+	}
+}
+
+func TestParseFragments(t *testing.T) {
+	got, err := quickadd.Parse("Оплатить интернет завтра каждый месяц 5 #дом !высокий", now)
+	require.NoError(t, err)
+	assert.Equal(t, []quickadd.Fragment{
+		{Text: "завтра", Kind: quickadd.KindDate, Value: "20250116"},
+		{Text: "каждый месяц 5", Kind: quickadd.KindRepeat, Value: "m 5"},
+		{Text: "#дом", Kind: quickadd.KindTag, Value: "дом"},
+		{Text: "!высокий", Kind: quickadd.KindPriority, Value: "3"},
+	}, got.Fragments)
+
+	got, err = quickadd.Parse("Купить средство", now)
+	require.NoError(t, err)
+	assert.Empty(t, got.Fragments)
+}
+
+func TestParseWithoutTitle(t *testing.T) {
+	_, err := quickadd.Parse("завтра #дом !!!", now)
+	assert.Error(t, err)
+}
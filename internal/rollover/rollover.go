@@ -0,0 +1,51 @@
+package rollover
+
+import (
+	"log"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+// DeletedTTL — сколько хранятся заметки и вложения удалённых задач; 0 — без
+// ограничения.
+var DeletedTTL = 30 * 24 * time.Hour
+
+// Run переносит просроченные повторяющиеся задачи и пишет каждый перенос в лог,
+// а затем удаляет заметки и вложения задач, удалённых раньше DeletedTTL.
+func Run(storage db.TaskStore, now time.Time) {
+	moved, err := storage.RolloverOverdue(now)
+	if err != nil {
+		log.Printf("Ошибка переноса просроченных задач: %v", err)
+	} else {
+		for _, m := range moved {
+			log.Printf("Задача %s «%s» перенесена с %s на %s", m.ID, m.Title, m.OldDate, m.NewDate)
+		}
+		log.Printf("Перенос просроченных задач завершён, перенесено: %d", len(moved))
+	}
+
+	if DeletedTTL == 0 {
+		return
+	}
+	purged, err := storage.PurgeDeleted(now.Add(-DeletedTTL))
+	if err != nil {
+		log.Printf("Ошибка очистки удалённых задач: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Удалены заметки и вложения удалённых задач: %d", purged)
+	}
+}
+
+// Start запускает перенос и очистку сразу и затем каждую ночь в полночь.
+func Start(storage db.TaskStore) {
+	go func() {
+		Run(storage, time.Now())
+		for {
+			now := time.Now()
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+			time.Sleep(midnight.Sub(now))
+			Run(storage, time.Now())
+		}
+	}()
+}
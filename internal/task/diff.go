@@ -0,0 +1,57 @@
+package task
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Change — изменение одного поля задачи.
+type Change struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// Diff перечисляет поля, которые отличаются у задач before и after.
+func Diff(before, after Task) []Change {
+	changes := make([]Change, 0)
+	add := func(field string, old, new any) {
+		if !reflect.DeepEqual(old, new) {
+			changes = append(changes, Change{Field: field, Old: old, New: new})
+		}
+	}
+
+	add("date", before.Date, after.Date)
+	add("title", before.Title, after.Title)
+	add("comment", before.Comment, after.Comment)
+	add("repeat", before.Repeat, after.Repeat)
+	add("deadline", before.Deadline, after.Deadline)
+	add("estimate", before.Estimate, after.Estimate)
+	add("priority", before.Priority, after.Priority)
+	add("tags", sortedTags(before.Tags), sortedTags(after.Tags))
+
+	names := make([]string, 0, len(before.Fields)+len(after.Fields))
+	for name := range before.Fields {
+		names = append(names, name)
+	}
+	for name := range after.Fields {
+		if _, ok := before.Fields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		add("fields."+name, before.Fields[name], after.Fields[name])
+	}
+
+	return changes
+}
+
+func sortedTags(tags []string) []string {
+	if len(tags) == 0 {
+		return []string{}
+	}
+	tags = slices.Clone(tags)
+	slices.Sort(tags)
+	return tags
+}
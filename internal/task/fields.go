@@ -0,0 +1,121 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+)
+
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldDate   = "date"
+	FieldEnum   = "enum"
+	FieldBool   = "bool"
+)
+
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
+// FieldDef описывает пользовательское поле. Если указана метка Tag, поле
+// относится к проекту с этой меткой и допустимо только у его задач.
+type FieldDef struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Options []string `json:"options,omitempty"`
+	Tag     string   `json:"tag,omitempty"`
+}
+
+func (d *FieldDef) Validate() error {
+	if !fieldNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("недопустимое имя поля %q", d.Name)
+	}
+	switch d.Type {
+	case FieldText, FieldNumber, FieldDate, FieldBool:
+		d.Options = nil
+	case FieldEnum:
+		if len(d.Options) == 0 {
+			return fmt.Errorf("не указаны значения перечисления")
+		}
+	default:
+		return fmt.Errorf("неподдерживаемый тип поля %q", d.Type)
+	}
+	return nil
+}
+
+// Normalize проверяет значение поля и приводит его к каноническому виду:
+// строке, числу float64 или bool. Значение может прийти строкой, например из
+// параметров запроса.
+func (d FieldDef) Normalize(value any) (any, error) {
+	switch d.Type {
+	case FieldText:
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+	case FieldNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, nil
+			}
+		}
+	case FieldDate:
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse(date.DATE_FORMAT, s); err == nil {
+				return s, nil
+			}
+		}
+	case FieldEnum:
+		if s, ok := value.(string); ok && slices.Contains(d.Options, s) {
+			return s, nil
+		}
+	case FieldBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("неверное значение поля %s", d.Name)
+}
+
+// ValidateFields проверяет пользовательские поля задачи по их описаниям.
+func (t *Task) ValidateFields(defs []FieldDef) error {
+	if len(t.Fields) == 0 {
+		return nil
+	}
+
+	byName := make(map[string]FieldDef, len(defs))
+	for _, d := range defs {
+		byName[d.Name] = d
+	}
+
+	for name, value := range t.Fields {
+		def, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("неизвестное поле %s", name)
+		}
+		if def.Tag != "" && !slices.Contains(t.Tags, def.Tag) {
+			return fmt.Errorf("поле %s доступно только задачам с меткой %s", name, def.Tag)
+		}
+		if value == nil {
+			delete(t.Fields, name)
+			continue
+		}
+		normalized, err := def.Normalize(value)
+		if err != nil {
+			return err
+		}
+		t.Fields[name] = normalized
+	}
+
+	return nil
+}
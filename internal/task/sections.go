@@ -0,0 +1,71 @@
+package task
+
+import (
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+)
+
+// Разделы списка задач в порядке вывода.
+const (
+	SectionOverdue  = "overdue"
+	SectionToday    = "today"
+	SectionTomorrow = "tomorrow"
+	SectionThisWeek = "this_week"
+	SectionLater    = "later"
+	SectionNoDate   = "no_date"
+)
+
+var sectionTitles = []struct{ key, title string }{
+	{SectionOverdue, "Просроченные"},
+	{SectionToday, "Сегодня"},
+	{SectionTomorrow, "Завтра"},
+	{SectionThisWeek, "На этой неделе"},
+	{SectionLater, "Позже"},
+	{SectionNoDate, "Без даты"},
+}
+
+// Section — раздел списка задач.
+type Section struct {
+	Key   string `json:"key"`
+	Title string `json:"title"`
+	Tasks []Task `json:"tasks"`
+}
+
+// Sections раскладывает задачи по разделам относительно дня now, сохраняя их
+// порядок внутри раздела. Неделя заканчивается воскресеньем; задачи на завтра
+// в раздел недели не попадают. Возвращаются все разделы, в том числе пустые.
+func Sections(tasks []Task, now time.Time) []Section {
+	today := now.Format(date.DATE_FORMAT)
+	tomorrow := now.AddDate(0, 0, 1).Format(date.DATE_FORMAT)
+	weekday := int(now.Weekday()+6) % 7 // 0 — понедельник
+	weekEnd := now.AddDate(0, 0, 6-weekday).Format(date.DATE_FORMAT)
+
+	sections := make([]Section, len(sectionTitles))
+	index := make(map[string]int, len(sectionTitles))
+	for i, s := range sectionTitles {
+		sections[i] = Section{Key: s.key, Title: s.title, Tasks: make([]Task, 0)}
+		index[s.key] = i
+	}
+
+	for _, t := range tasks {
+		var key string
+		switch {
+		case t.Date == "":
+			key = SectionNoDate
+		case t.Date < today || (t.Deadline != "" && t.Deadline < today):
+			key = SectionOverdue
+		case t.Date == today:
+			key = SectionToday
+		case t.Date == tomorrow:
+			key = SectionTomorrow
+		case t.Date <= weekEnd:
+			key = SectionThisWeek
+		default:
+			key = SectionLater
+		}
+		i := index[key]
+		sections[i].Tasks = append(sections[i].Tasks, t)
+	}
+	return sections
+}
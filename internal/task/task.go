@@ -2,17 +2,36 @@ package task
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/imbalaancing/go_final_project/internal/date"
 )
 
+// StrictDates отключает перенос прошедших дат на сегодня: такие задачи
+// сохраняются как есть и считаются просроченными.
+var StrictDates = false
+
+const (
+	PriorityLow = iota + 1
+	PriorityMedium
+	PriorityHigh
+)
+
 type Task struct {
-	ID      string `json:"id"`
-	Date    string `json:"date"`
-	Title   string `json:"title"`
-	Comment string `json:"comment,omitempty"`
-	Repeat  string `json:"repeat"`
+	ID       string         `json:"id"`
+	Date     string         `json:"date"`
+	Title    string         `json:"title"`
+	Comment  string         `json:"comment,omitempty"`
+	Repeat   string         `json:"repeat"`
+	Deadline string         `json:"deadline,omitempty"`
+	Overdue  bool           `json:"overdue,omitempty"`
+	Tags     []string       `json:"tags,omitempty"`
+	Estimate int            `json:"estimate,omitempty"` // оценка длительности в минутах
+	Priority int            `json:"priority,omitempty"` // 0 — не задан, 1 — низкий, 2 — средний, 3 — высокий
+	Fields   map[string]any `json:"fields,omitempty"`
+	Snippet  string         `json:"snippet,omitempty"` // фрагмент с найденными словами, только в результатах поиска
+	Version  int64          `json:"-"`                 // растёт при каждом изменении задачи, отдаётся в ETag
 }
 
 func (t *Task) ValidateTask() error {
@@ -26,19 +45,105 @@ func (t *Task) ValidateTask() error {
 		}
 	}
 
-	if t.Date == "" || t.Date < time.Now().Format(date.DATE_FORMAT) {
-		t.Date = time.Now().Format(date.DATE_FORMAT)
+	if t.Deadline != "" {
+		if _, err := time.Parse(date.DATE_FORMAT, t.Deadline); err != nil {
+			return fmt.Errorf("неверный формат крайнего срока")
+		}
+		if t.Date != "" && t.Date > t.Deadline {
+			return fmt.Errorf("дата выполнения позже крайнего срока")
+		}
 	}
 
-	if t.Repeat == "d 1" || t.Repeat == "d 5" || t.Repeat == "d 3" {
-		t.Date = time.Now().Format(date.DATE_FORMAT)
-	} else if t.Repeat != "" {
-		newDate, err := date.NextDate(time.Now(), t.Date, t.Repeat)
+	if t.Priority < 0 || t.Priority > PriorityHigh {
+		return fmt.Errorf("неверный приоритет")
+	}
+
+	if t.Estimate < 0 {
+		return fmt.Errorf("оценка длительности не может быть отрицательной")
+	}
+
+	if err := t.normalizeTags(); err != nil {
+		return err
+	}
+
+	if StrictDates {
+		if err := t.validateStrict(); err != nil {
+			return err
+		}
+	} else if err := t.normalizeDate(); err != nil {
+		return err
+	}
+
+	// Перенесённая дата не должна уйти за срок, который ещё не прошёл. Задача
+	// с прошедшим сроком просто просрочена, и её можно править.
+	if t.Deadline != "" && t.Deadline >= time.Now().Format(date.DATE_FORMAT) && t.Date > t.Deadline {
+		return fmt.Errorf("дата выполнения позже крайнего срока")
+	}
+
+	return nil
+}
+
+// normalizeDate переносит дату задачи, если строгие даты отключены.
+// Прошедшая дата переносится на сегодня, а у повторяющейся задачи — на
+// ближайшую дату по правилу. Сегодняшняя и будущие даты не меняются и у
+// повторяющихся задач: эту дату пользователь выбрал сам, и по ней задачу
+// находит поиск по дате.
+func (t *Task) normalizeDate() error {
+	today := time.Now().Format(date.DATE_FORMAT)
+	if t.Date == "" {
+		t.Date = today
+	}
+	if t.Repeat != "" {
+		next, err := date.NextDate(time.Now(), t.Date, t.Repeat)
 		if err != nil {
 			return err
 		}
-		t.Date = newDate
+		if t.Date < today {
+			t.Date = next
+		}
+	}
+	if t.Date < today {
+		t.Date = today
 	}
 
 	return nil
 }
+
+func (t *Task) validateStrict() error {
+	if t.Date == "" {
+		t.Date = time.Now().Format(date.DATE_FORMAT)
+	}
+
+	if t.Repeat != "" {
+		if _, err := date.NextDate(time.Now(), t.Date, t.Repeat); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeTags приводит метки к нижнему регистру, убирает ведущий # и дубликаты.
+func (t *Task) normalizeTags() error {
+	tags := make([]string, 0, len(t.Tags))
+	seen := make(map[string]bool)
+	for _, tag := range t.Tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if strings.ContainsAny(tag, ", \t") {
+			return fmt.Errorf("недопустимая метка %q", tag)
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	t.Tags = tags
+	return nil
+}
+
+// SetOverdue отмечает задачу просроченной, если прошла её дата или крайний срок.
+func (t *Task) SetOverdue(now time.Time) {
+	today := now.Format(date.DATE_FORMAT)
+	t.Overdue = t.Date < today || (t.Deadline != "" && t.Deadline < today)
+}
@@ -0,0 +1,103 @@
+package task_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+func day(n int) string {
+	return time.Now().AddDate(0, 0, n).Format(date.DATE_FORMAT)
+}
+
+func TestValidateTaskDate(t *testing.T) {
+	tests := []struct {
+		name   string
+		date   string
+		repeat string
+		want   string
+	}{
+		{"без даты", "", "", day(0)},
+		{"прошедшая", day(-3), "", day(0)},
+		{"сегодня", day(0), "", day(0)},
+		{"будущая", day(5), "", day(5)},
+		{"прошедшая с повторением", day(-3), "d 7", day(4)},
+		{"прошедшая с ежедневным повторением", day(-2), "d 1", day(1)},
+		{"прошедшая с повторением раз в 5 дней", day(-12), "d 5", day(3)},
+		{"сегодня с повторением", day(0), "d 7", day(0)},
+		{"будущая с повторением", day(5), "d 7", day(5)},
+		{"будущая с ежедневным повторением", day(2), "d 1", day(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := task.Task{Title: "Задача", Date: tt.date, Repeat: tt.repeat}
+			require.NoError(t, got.ValidateTask())
+			assert.Equal(t, tt.want, got.Date)
+		})
+	}
+
+	bad := task.Task{Title: "Задача", Date: day(-1), Repeat: "d 0"}
+	assert.Error(t, bad.ValidateTask())
+}
+
+func TestValidateTaskDeadline(t *testing.T) {
+	tests := []struct {
+		name string
+		task task.Task
+		ok   bool
+	}{
+		{"срок после даты", task.Task{Date: day(1), Deadline: day(2)}, true},
+		{"срок в день даты", task.Task{Date: day(1), Deadline: day(1)}, true},
+		{"срок до даты", task.Task{Date: day(2), Deadline: day(1)}, false},
+		{"без даты срок сегодня", task.Task{Deadline: day(0)}, true},
+		// Задача с прошедшим сроком просрочена, но остаётся допустимой.
+		{"без даты срок прошёл", task.Task{Deadline: day(-1)}, true},
+		{"прошедшие дата и срок", task.Task{Date: day(-3), Deadline: day(-1)}, true},
+		{"прошедшая дата после прошедшего срока", task.Task{Date: day(-1), Deadline: day(-3)}, false},
+		{"прошедшая дата, срок впереди", task.Task{Date: day(-3), Deadline: day(1)}, true},
+		{"повторение переносит дату за срок", task.Task{Date: day(-1), Deadline: day(0), Repeat: "d 7"}, false},
+		{"повторение переносит дату за будущий срок", task.Task{Date: day(-1), Deadline: day(2), Repeat: "d 7"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.task.Title = "Задача"
+			err := tt.task.ValidateTask()
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, "дата выполнения позже крайнего срока")
+			}
+		})
+	}
+}
+
+// TestValidateTaskOverdueEdit проверяет, что у просроченной задачи можно
+// изменить заголовок: её дата переносится на сегодня, но срок уже прошёл.
+func TestValidateTaskOverdueEdit(t *testing.T) {
+	stored := task.Task{ID: "1", Title: "Сдать отчёт", Date: day(-3), Deadline: day(-1)}
+
+	edited := stored
+	edited.Title = "Сдать квартальный отчёт"
+	require.NoError(t, edited.ValidateTask())
+	assert.Equal(t, day(0), edited.Date)
+	assert.Equal(t, day(-1), edited.Deadline)
+}
+
+func TestValidateTaskStrictDeadline(t *testing.T) {
+	task.StrictDates = true
+	t.Cleanup(func() { task.StrictDates = false })
+
+	// В строгом режиме дата не переносится, поэтому прошедшие дата и срок
+	// допустимы.
+	tt := task.Task{Title: "Задача", Date: day(-3), Deadline: day(-1)}
+	require.NoError(t, tt.ValidateTask())
+	assert.Equal(t, day(-3), tt.Date)
+
+	tt = task.Task{Title: "Задача", Date: day(1), Deadline: day(-1)}
+	assert.EqualError(t, tt.ValidateTask(), "дата выполнения позже крайнего срока")
+}
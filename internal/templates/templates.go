@@ -0,0 +1,162 @@
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/date"
+	"github.com/imbalaancing/go_final_project/internal/task"
+)
+
+// Template — набор задач, которые создаются вместе. В заголовках, комментариях
+// и метках допускаются подстановки вида {{name}}, а даты можно задавать
+// относительно даты начала: "+3d", "+2w", "+1m".
+type Template struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Tasks       []TaskSpec `json:"tasks"`
+}
+
+type TaskSpec struct {
+	Title    string   `json:"title"`
+	Comment  string   `json:"comment,omitempty"`
+	Date     string   `json:"date,omitempty"`
+	Deadline string   `json:"deadline,omitempty"`
+	Repeat   string   `json:"repeat,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+	Estimate int      `json:"estimate,omitempty"`
+	Priority int      `json:"priority,omitempty"`
+}
+
+var (
+	placeholderPattern  = regexp.MustCompile(`\{\{\s*([\p{L}\p{N}_]+)\s*\}\}`)
+	relativeDatePattern = regexp.MustCompile(`^\+(\d{1,3})([dwm])$`)
+)
+
+func (t *Template) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("не указано название шаблона")
+	}
+	if len(t.Tasks) == 0 {
+		return fmt.Errorf("шаблон не содержит задач")
+	}
+
+	for i, spec := range t.Tasks {
+		if spec.Title == "" {
+			return fmt.Errorf("задача %d: не указан заголовок", i+1)
+		}
+		for _, d := range []string{spec.Date, spec.Deadline} {
+			if _, err := resolveDate(d, time.Now()); err != nil {
+				return fmt.Errorf("задача %d: %v", i+1, err)
+			}
+		}
+		if spec.Repeat != "" {
+			if _, err := date.NextDate(time.Now(), time.Now().Format(date.DATE_FORMAT), spec.Repeat); err != nil {
+				return fmt.Errorf("задача %d: %v", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Placeholders возвращает имена всех подстановок шаблона.
+func (t *Template) Placeholders() []string {
+	seen := make(map[string]bool)
+	var names []string
+	collect := func(s string) {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(s, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				names = append(names, m[1])
+			}
+		}
+	}
+	for _, spec := range t.Tasks {
+		collect(spec.Title)
+		collect(spec.Comment)
+		for _, tag := range spec.Tags {
+			collect(tag)
+		}
+	}
+	return names
+}
+
+// Instantiate строит задачи шаблона, подставляя params и отсчитывая
+// относительные даты от start.
+func (t *Template) Instantiate(params map[string]string, start time.Time) ([]task.Task, error) {
+	var missing []string
+	for _, name := range t.Placeholders() {
+		if _, ok := params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("не заданы значения: %s", strings.Join(missing, ", "))
+	}
+
+	fill := func(s string) string {
+		return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+			return params[placeholderPattern.FindStringSubmatch(m)[1]]
+		})
+	}
+
+	tasks := make([]task.Task, 0, len(t.Tasks))
+	for _, spec := range t.Tasks {
+		d, err := resolveDate(spec.Date, start)
+		if err != nil {
+			return nil, err
+		}
+		deadline, err := resolveDate(spec.Deadline, start)
+		if err != nil {
+			return nil, err
+		}
+
+		tags := make([]string, 0, len(spec.Tags))
+		for _, tag := range spec.Tags {
+			tags = append(tags, fill(tag))
+		}
+
+		tasks = append(tasks, task.Task{
+			Date:     d,
+			Title:    fill(spec.Title),
+			Comment:  fill(spec.Comment),
+			Repeat:   spec.Repeat,
+			Deadline: deadline,
+			Tags:     tags,
+			Estimate: spec.Estimate,
+			Priority: spec.Priority,
+		})
+	}
+	return tasks, nil
+}
+
+// resolveDate переводит относительную дату в абсолютную. Абсолютные и пустые
+// даты возвращаются как есть.
+func resolveDate(value string, start time.Time) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	m := relativeDatePattern.FindStringSubmatch(value)
+	if m == nil {
+		if _, err := time.Parse(date.DATE_FORMAT, value); err != nil {
+			return "", fmt.Errorf("неверный формат даты %q", value)
+		}
+		return value, nil
+	}
+
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "d":
+		start = start.AddDate(0, 0, n)
+	case "w":
+		start = start.AddDate(0, 0, 7*n)
+	case "m":
+		start = start.AddDate(0, n, 0)
+	}
+	return start.Format(date.DATE_FORMAT), nil
+}
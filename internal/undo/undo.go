@@ -0,0 +1,89 @@
+package undo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/imbalaancing/go_final_project/internal/db"
+)
+
+const (
+	DefaultTTL = 10 * time.Minute
+	maxDepth   = 20
+)
+
+// Entry — отменяемая операция и обратные шаги для неё.
+type Entry struct {
+	Op        string
+	Steps     []db.UndoStep
+	CreatedAt time.Time
+}
+
+// Stack хранит для каждой сессии последние отменяемые операции. Записи старше
+// ttl отбрасываются.
+type Stack struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string][]Entry
+}
+
+func NewStack(ttl time.Duration) *Stack {
+	return &Stack{ttl: ttl, sessions: make(map[string][]Entry)}
+}
+
+func (s *Stack) Push(session, op string, steps []db.UndoStep) {
+	if len(steps) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(time.Now())
+	entries := append(s.sessions[session], Entry{Op: op, Steps: steps, CreatedAt: time.Now()})
+	if len(entries) > maxDepth {
+		entries = entries[len(entries)-maxDepth:]
+	}
+	s.sessions[session] = entries
+}
+
+// Pop снимает последнюю неистёкшую операцию сессии.
+func (s *Stack) Pop(session string) (Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(time.Now())
+	entries := s.sessions[session]
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+
+	last := entries[len(entries)-1]
+	if len(entries) == 1 {
+		delete(s.sessions, session)
+	} else {
+		s.sessions[session] = entries[:len(entries)-1]
+	}
+	return last, true
+}
+
+// Return кладёт снятую операцию обратно, например если отменить её не удалось.
+func (s *Stack) Return(session string, e Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session] = append(s.sessions[session], e)
+}
+
+func (s *Stack) pruneLocked(now time.Time) {
+	for session, entries := range s.sessions {
+		i := 0
+		for i < len(entries) && now.Sub(entries[i].CreatedAt) > s.ttl {
+			i++
+		}
+		if i == len(entries) {
+			delete(s.sessions, session)
+		} else if i > 0 {
+			s.sessions[session] = entries[i:]
+		}
+	}
+}